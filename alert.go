@@ -0,0 +1,397 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"sort"
+	"time"
+
+	. "github.com/tendermint/go-common"
+	client "github.com/tendermint/go-rpc/client"
+	"github.com/tendermint/go-wire"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+	tmtypes "github.com/tendermint/tendermint/types"
+
+	"github.com/codegangsta/cli"
+	"gopkg.in/yaml.v2"
+)
+
+const alertReconnectDelay = 3 * time.Second
+
+var defaultAlertFor = map[string]time.Duration{
+	"no-block":     30 * time.Second,
+	"disconnected": 10 * time.Second,
+}
+
+// Alert rules and the hooks to fire when they match, read from yaml. See the README for an example
+type AlertConfig struct {
+	Rules []*AlertRule `yaml:"rules"`
+	Hooks []*AlertHook `yaml:"hooks"`
+}
+
+type AlertRule struct {
+	Name string `yaml:"name"` // defaults to the kind
+	Kind string `yaml:"kind"` // no-block, height-divergence or disconnected
+	For  string `yaml:"for"`  // how long no-block and disconnected must last before firing
+	Max  int    `yaml:"max"`  // the largest height difference height-divergence allows
+
+	forDuration time.Duration
+}
+
+// One of shell, webhook or file
+type AlertHook struct {
+	Shell   string `yaml:"shell"`   // run with sh -c, with the alert in MINTNET_ALERT_* variables
+	Webhook string `yaml:"webhook"` // POSTed the alert as json
+	File    string `yaml:"file"`    // appended the alert as a json line
+}
+
+type alert struct {
+	Rule    string `json:"rule"`
+	Mach    string `json:"mach,omitempty"` // empty for rules about the whole network
+	Status  string `json:"status"`         // firing or resolved
+	Message string `json:"message"`
+	Time    string `json:"time"`
+}
+
+// What the event subscriptions tell about a node
+type eventNode struct {
+	Connected bool
+	Since     time.Time // when it last connected or disconnected
+	Err       error     // why it disconnected
+	Height    int
+	LastBlock time.Time // when a new block last came, or when it connected
+	Txs       int       // tx events received
+}
+
+// A new block, a tx, or a change of connection, from a node's subscription
+type nodeEvent struct {
+	Mach      string
+	Connected bool
+	Height    int  // 0 if not a new block
+	Tx        bool // whether it's a tx event
+	Err       error
+}
+
+func LoadAlertConfig(file string) (*AlertConfig, error) {
+	configBytes, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	config := new(AlertConfig)
+	if err := yaml.Unmarshal(configBytes, config); err != nil {
+		return nil, errors.New(Fmt("Invalid alert rules %v: %v", file, err))
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Checks the rules and hooks, filling in the defaults
+func (config *AlertConfig) Validate() error {
+	if len(config.Rules) == 0 {
+		return errors.New("No alert rules")
+	}
+	for i, rule := range config.Rules {
+		switch rule.Kind {
+		case "no-block", "disconnected":
+			rule.forDuration = defaultAlertFor[rule.Kind]
+			if rule.For != "" {
+				forDuration, err := time.ParseDuration(rule.For)
+				if err != nil {
+					return errors.New(Fmt("Invalid duration %q in rule %v", rule.For, i+1))
+				}
+				rule.forDuration = forDuration
+			}
+		case "height-divergence":
+			if rule.Max < 1 {
+				return errors.New(Fmt("Rule %v needs a max of at least 1", i+1))
+			}
+		default:
+			return errors.New(Fmt("Unknown kind %q in rule %v", rule.Kind, i+1))
+		}
+		if rule.Name == "" {
+			rule.Name = rule.Kind
+		}
+	}
+	for i, hook := range config.Hooks {
+		set := 0
+		for _, target := range []string{hook.Shell, hook.Webhook, hook.File} {
+			if target != "" {
+				set++
+			}
+		}
+		if set != 1 {
+			return errors.New(Fmt("Hook %v needs exactly one of shell, webhook or file", i+1))
+		}
+	}
+	return nil
+}
+
+//--------------------------------------------------------------------------------
+
+// Subscribe to every node's new blocks and txs, and fire the hooks when an alert rule starts or stops matching
+func cmdAlert(c *cli.Context) {
+	args := c.Args()
+	machines, state := parseNetworkMachines(c)
+	app, ok := parseNetworkApp(args, state)
+	if !ok {
+		cli.ShowAppHelp(c)
+		return
+	}
+	config, err := LoadAlertConfig(c.String("rules"))
+	if err != nil {
+		Exit(err.Error())
+	}
+
+	events := make(chan nodeEvent)
+	nodes := make(map[string]*eventNode)
+	start := time.Now()
+	for _, mach := range machines {
+		rpcAddr, err := getRPCAddr(state, mach, app)
+		if err != nil {
+			Exit(err.Error())
+		}
+		nodes[mach] = &eventNode{Since: start}
+		go subscribeNode(mach, rpcAddr, events)
+	}
+	fmt.Println(Green(Fmt("Watching %v nodes with %v rules", len(machines), len(config.Rules))))
+
+	firing := make(map[string]*alert)
+	ticker := time.NewTicker(time.Second)
+	for {
+		select {
+		case event := <-events:
+			nodes[event.Mach].apply(event, time.Now())
+		case <-ticker.C:
+		}
+		now := time.Now()
+		current := evaluateAlerts(config.Rules, machines, nodes, now)
+		for _, a := range alertChanges(firing, current, now) {
+			if a.Status == "firing" {
+				fmt.Println(Red(Fmt("%v FIRING %v: %v", a.Time, a.Rule, a.Message)))
+			} else {
+				fmt.Println(Green(Fmt("%v RESOLVED %v: %v", a.Time, a.Rule, a.Message)))
+			}
+			for _, hook := range config.Hooks {
+				go func(hook *AlertHook, a *alert) {
+					if err := hook.Fire(a); err != nil {
+						fmt.Println(Yellow("Alert hook failed: " + err.Error()))
+					}
+				}(hook, a)
+			}
+		}
+		firing = current
+	}
+}
+
+// Keeps a node subscribed, reconnecting when the connection drops
+func subscribeNode(mach, rpcAddr string, events chan<- nodeEvent) {
+	for {
+		err := streamEvents(mach, rpcAddr, events)
+		events <- nodeEvent{Mach: mach, Err: err}
+		time.Sleep(alertReconnectDelay)
+	}
+}
+
+// Sends an event for each new block and tx until the connection fails.
+// Tendermint names tx events by the tx, so we subscribe to the txs of each new block,
+// dropping those of the block before.
+func streamEvents(mach, rpcAddr string, events chan<- nodeEvent) error {
+	ws := client.NewWSClient(rpcAddr, "/websocket")
+	if _, err := ws.Start(); err != nil {
+		return err
+	}
+	defer ws.Stop()
+	if err := ws.Subscribe(tmtypes.EventStringNewBlock()); err != nil {
+		return err
+	}
+	events <- nodeEvent{Mach: mach, Connected: true}
+	txEvents := []string{}
+	for {
+		select {
+		case raw, ok := <-ws.ResultsCh:
+			if !ok {
+				return errors.New("Connection closed")
+			}
+			var result ctypes.TMResult
+			var err error
+			wire.ReadJSONPtr(&result, raw, &err)
+			if err != nil {
+				continue
+			}
+			event, ok := result.(*ctypes.ResultEvent)
+			if !ok {
+				continue
+			}
+			switch data := event.Data.(type) {
+			case tmtypes.EventDataNewBlock:
+				if data.Block == nil {
+					continue
+				}
+				for _, txEvent := range txEvents {
+					if err := ws.Unsubscribe(txEvent); err != nil {
+						return err
+					}
+				}
+				txEvents = txEvents[:0]
+				for _, tx := range data.Block.Data.Txs {
+					txEvent := tmtypes.EventStringTx(tx)
+					if err := ws.Subscribe(txEvent); err != nil {
+						return err
+					}
+					txEvents = append(txEvents, txEvent)
+				}
+				events <- nodeEvent{Mach: mach, Connected: true, Height: data.Block.Height}
+			case tmtypes.EventDataTx:
+				events <- nodeEvent{Mach: mach, Connected: true, Tx: true}
+			}
+		case err := <-ws.ErrorsCh:
+			return err
+		}
+	}
+}
+
+func (node *eventNode) apply(event nodeEvent, now time.Time) {
+	if event.Connected != node.Connected {
+		node.Connected = event.Connected
+		node.Since = now
+		if event.Connected {
+			node.LastBlock = now
+		}
+	}
+	if !event.Connected {
+		node.Err = event.Err
+	}
+	if event.Height > node.Height {
+		node.Height = event.Height
+		node.LastBlock = now
+	}
+	if event.Tx {
+		node.Txs++
+	}
+}
+
+//--------------------------------------------------------------------------------
+
+// Returns the alerts the rules match now, by rule and machine
+func evaluateAlerts(rules []*AlertRule, machines []string, nodes map[string]*eventNode, now time.Time) map[string]*alert {
+	alerts := make(map[string]*alert)
+	add := func(rule *AlertRule, mach, message string) {
+		alerts[rule.Name+"/"+mach] = &alert{Rule: rule.Name, Mach: mach, Status: "firing", Message: message}
+	}
+	for _, rule := range rules {
+		switch rule.Kind {
+		case "no-block":
+			for _, mach := range machines {
+				node := nodes[mach]
+				if since := now.Sub(node.LastBlock); node.Connected && since >= rule.forDuration {
+					add(rule, mach, Fmt("No new block from %v for %v, last at height %v after %v txs", mach, since/time.Second*time.Second, node.Height, node.Txs))
+				}
+			}
+		case "disconnected":
+			for _, mach := range machines {
+				node := nodes[mach]
+				if since := now.Sub(node.Since); !node.Connected && since >= rule.forDuration {
+					message := Fmt("%v disconnected for %v", mach, since/time.Second*time.Second)
+					if node.Err != nil {
+						message += ": " + node.Err.Error()
+					}
+					add(rule, mach, message)
+				}
+			}
+		case "height-divergence":
+			lowest, highest := "", ""
+			for _, mach := range machines {
+				node := nodes[mach]
+				if !node.Connected || node.Height == 0 {
+					continue
+				}
+				if lowest == "" || node.Height < nodes[lowest].Height {
+					lowest = mach
+				}
+				if highest == "" || node.Height > nodes[highest].Height {
+					highest = mach
+				}
+			}
+			if lowest != "" && nodes[highest].Height-nodes[lowest].Height > rule.Max {
+				add(rule, "", Fmt("Heights differ by %v: %v at %v, %v at %v", nodes[highest].Height-nodes[lowest].Height,
+					highest, nodes[highest].Height, lowest, nodes[lowest].Height))
+			}
+		}
+	}
+	return alerts
+}
+
+// Returns the alerts that started firing, then the ones that resolved, each sorted by key
+func alertChanges(prev, current map[string]*alert, now time.Time) []*alert {
+	changes := []*alert{}
+	for _, key := range sortedAlertKeys(current) {
+		if _, ok := prev[key]; !ok {
+			a := *current[key]
+			a.Time = now.Format(time.RFC3339)
+			changes = append(changes, &a)
+		}
+	}
+	for _, key := range sortedAlertKeys(prev) {
+		if _, ok := current[key]; !ok {
+			a := *prev[key]
+			a.Status = "resolved"
+			a.Time = now.Format(time.RFC3339)
+			changes = append(changes, &a)
+		}
+	}
+	return changes
+}
+
+func sortedAlertKeys(alerts map[string]*alert) []string {
+	keys := []string{}
+	for key := range alerts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (hook *AlertHook) Fire(a *alert) error {
+	alertJSON, _ := json.Marshal(a)
+	switch {
+	case hook.Shell != "":
+		cmd := exec.Command("sh", "-c", hook.Shell)
+		cmd.Env = append(os.Environ(),
+			"MINTNET_ALERT_RULE="+a.Rule,
+			"MINTNET_ALERT_MACH="+a.Mach,
+			"MINTNET_ALERT_STATUS="+a.Status,
+			"MINTNET_ALERT_MESSAGE="+a.Message,
+			"MINTNET_ALERT_JSON="+string(alertJSON),
+		)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return errors.New(Fmt("%v: %v %v", hook.Shell, err, string(output)))
+		}
+	case hook.Webhook != "":
+		resp, err := http.Post(hook.Webhook, "application/json", bytes.NewReader(alertJSON))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			return errors.New(Fmt("%v answered %v", hook.Webhook, resp.Status))
+		}
+	case hook.File != "":
+		f, err := os.OpenFile(hook.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if _, err := f.Write(append(alertJSON, '\n')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
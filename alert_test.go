@@ -0,0 +1,115 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestAlertConfigValidate(t *testing.T) {
+	configYAML := `
+rules:
+  - {kind: no-block}
+  - {name: down, kind: disconnected, for: 5s}
+  - {kind: height-divergence, max: 3}
+hooks:
+  - {file: alerts.log}
+`
+	config := new(AlertConfig)
+	if err := yaml.Unmarshal([]byte(configYAML), config); err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if config.Rules[0].Name != "no-block" || config.Rules[0].forDuration != 30*time.Second {
+		t.Errorf("Expected the defaults for no-block, got %v and %v", config.Rules[0].Name, config.Rules[0].forDuration)
+	}
+	if config.Rules[1].forDuration != 5*time.Second {
+		t.Errorf("Expected 5s for disconnected, got %v", config.Rules[1].forDuration)
+	}
+
+	for _, invalid := range []*AlertConfig{
+		{},
+		{Rules: []*AlertRule{{Kind: "unknown"}}},
+		{Rules: []*AlertRule{{Kind: "height-divergence"}}},
+		{Rules: []*AlertRule{{Kind: "no-block", For: "soon"}}},
+		{Rules: []*AlertRule{{Kind: "no-block"}}, Hooks: []*AlertHook{{Shell: "true", File: "alerts.log"}}},
+	} {
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("Expected error for %+v", invalid)
+		}
+	}
+}
+
+func TestEvaluateAlerts(t *testing.T) {
+	rules := []*AlertRule{
+		{Name: "no-block", Kind: "no-block", forDuration: 30 * time.Second},
+		{Name: "disconnected", Kind: "disconnected", forDuration: 10 * time.Second},
+		{Name: "height-divergence", Kind: "height-divergence", Max: 2},
+	}
+	start := time.Now()
+	machines := []string{"mach1", "mach2", "mach3"}
+	nodes := map[string]*eventNode{}
+	for _, mach := range machines {
+		nodes[mach] = &eventNode{Since: start}
+	}
+	nodes["mach1"].apply(nodeEvent{Mach: "mach1", Connected: true}, start)
+	nodes["mach1"].apply(nodeEvent{Mach: "mach1", Connected: true, Height: 10}, start)
+	nodes["mach2"].apply(nodeEvent{Mach: "mach2", Connected: true}, start)
+	nodes["mach2"].apply(nodeEvent{Mach: "mach2", Connected: true, Height: 7}, start)
+
+	firing := evaluateAlerts(rules, machines, nodes, start.Add(5*time.Second))
+	if len(firing) != 1 || firing["height-divergence/"] == nil {
+		t.Fatalf("Expected only the height divergence, got %v", sortedAlertKeys(firing))
+	}
+
+	// mach3 never connects and mach2 stops sending blocks
+	nodes["mach2"].apply(nodeEvent{Mach: "mach2", Connected: true, Height: 9}, start.Add(5*time.Second))
+	nodes["mach2"].apply(nodeEvent{Mach: "mach2", Connected: true, Tx: true}, start.Add(6*time.Second))
+	nodes["mach1"].apply(nodeEvent{Mach: "mach1", Connected: true, Height: 11}, start.Add(30*time.Second))
+	now := start.Add(40 * time.Second)
+	current := evaluateAlerts(rules, machines, nodes, now)
+	keys := strings.Join(sortedAlertKeys(current), ",")
+	if keys != "disconnected/mach3,no-block/mach2" {
+		t.Fatalf("Unexpected alerts %v", keys)
+	}
+
+	if message := current["no-block/mach2"].Message; message != "No new block from mach2 for 35s, last at height 9 after 1 txs" {
+		t.Errorf("Unexpected message %q", message)
+	}
+
+	changes := alertChanges(firing, current, now)
+	statuses := []string{}
+	for _, a := range changes {
+		statuses = append(statuses, a.Rule+":"+a.Status)
+	}
+	if strings.Join(statuses, ",") != "disconnected:firing,no-block:firing,height-divergence:resolved" {
+		t.Errorf("Unexpected changes %v", statuses)
+	}
+}
+
+func TestAlertHookFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mintnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := path.Join(dir, "alerts.log")
+	hook := &AlertHook{File: file}
+	for _, status := range []string{"firing", "resolved"} {
+		if err := hook.Fire(&alert{Rule: "no-block", Mach: "mach1", Status: status}); err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+	}
+	logged, _ := ioutil.ReadFile(file)
+	lines := strings.Split(strings.TrimSpace(string(logged)), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[1], `"status":"resolved"`) {
+		t.Errorf("Unexpected alerts log %q", logged)
+	}
+}
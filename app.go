@@ -33,57 +33,31 @@ func cmdStart(c *cli.Context) {
 		seeds = strings.Split(seedsStr, ",")
 	}
 	noTMSP := c.Bool("no-tmsp")
+	proj := ParseProject(c, base)
+	if err := proj.ValidateAppMachines(machines); err != nil {
+		Exit(err.Error())
+	}
 
-	// Initialize TMData, TMApp, and TMCore container on each machine
+	// Start the services of each node in dependency order.
 	// We let nodes boot and then detect which port they're listening on to collect CoreInfos
-	var wg sync.WaitGroup
-	coreInfosCh := make(chan *CoreInfo, len(machines))
-	errCh := make(chan error, len(machines))
-	for _, mach := range machines {
-		wg.Add(1)
-		go func(mach string) {
-			defer wg.Done()
-			if err := startTMCommon(mach, app); err != nil {
-				errCh <- err
-				return
-			}
-			if err := copyNodeDir(mach, app, base); err != nil {
-				errCh <- err
-				return
-			}
-
-			// if noTMSP, we ignore socket and app containers
-			// and just use an in-process null app
-			if !noTMSP {
-				if err := startTMData(mach, app); err != nil {
-					errCh <- err
-					return
-				}
-				if err := startTMApp(mach, app); err != nil {
-					errCh <- err
-					return
-				}
-			}
-
-			coreInfo, err := startTMCore(mach, app, nil, randomPorts, noTMSP)
-			if err != nil {
-				errCh <- err
-				return
-			}
-			coreInfosCh <- coreInfo
-		}(mach)
-	}
-	wg.Wait()
+	coreInfos := make([]*CoreInfo, len(machines))
+	nodeStates := make([]*NodeState, len(machines))
+	results := fanOut(machines, c.Int("parallel"), func(res *machResult) {
+		coreInfo, err := startNode(res.Mach, app, base, proj, randomPorts, noTMSP)
+		if res.Step("start", err) != nil {
+			return
+		}
+		coreInfos[res.Index] = coreInfo
+		nodeState, err := getNodeState(res.Mach, app, proj, coreInfo)
+		if res.Step("inspect", err) == nil {
+			nodeStates[res.Index] = nodeState
+		}
+	})
 
-	// Collect coreInfos, and maybe append seeds
-	var coreInfos []*CoreInfo
-	for i := 0; i < len(machines); i++ {
-		select {
-		case err := <-errCh:
-			fmt.Println(Red(err.Error()))
-		case coreInfo := <-coreInfosCh:
-			coreInfos = append(coreInfos, coreInfo)
-			if seedsStr == "" {
+	// Maybe append seeds
+	if seedsStr == "" {
+		for _, coreInfo := range coreInfos {
+			if coreInfo != nil {
 				seeds = append(seeds, coreInfo.P2PAddr)
 			}
 		}
@@ -91,19 +65,75 @@ func cmdStart(c *cli.Context) {
 
 	// Dial the seeds
 	fmt.Println(Green("Instruct nodes to dial each other"))
-	for _, core := range coreInfos {
+	var wg sync.WaitGroup
+	for i, coreInfo := range coreInfos {
+		if coreInfo == nil {
+			continue
+		}
 		wg.Add(1)
-		go func(rpcAddr string) {
+		go func(res *machResult, rpcAddr string) {
 			defer wg.Done()
-			if err := dialSeeds(rpcAddr, seeds); err != nil {
-				fmt.Println(Red(err.Error()))
-				return
-			}
-		}(core.RPCAddr)
+			res.Step("dial seeds", dialSeeds(rpcAddr, seeds))
+		}(results[i], coreInfo.RPCAddr)
 	}
 	wg.Wait()
 
+	// Remember the network for later commands, unless there's nothing to remember,
+	// so a failed start doesn't lose track of a network that is running
+	if nodes := compactNodeStates(nodeStates); len(nodes) == 0 {
+		fmt.Println(Yellow("No node started, leaving the network state as it was"))
+	} else if err := saveNetworkState(app, base, nodes, true); err != nil {
+		fmt.Println(Red("Failed to save network state: " + err.Error()))
+	} else {
+		fmt.Println(Green("Saved network state to " + path.Join(base, NetworkFileName)))
+	}
+
 	fmt.Println(Green("Done launching tendermint network for " + app))
+	exitWithResults(results)
+}
+
+// Starts tmcommon, copies the node's directories, then starts each service once
+// its dependencies are ready. Returns the CoreInfo collected from tmcore.
+func startNode(mach, app, base string, proj *Project, randomPorts, noTMSP bool) (*CoreInfo, error) {
+	services, err := proj.NodeServices(mach)
+	if err != nil {
+		return nil, err
+	}
+	if err := startTMCommon(mach, app, proj); err != nil {
+		return nil, err
+	}
+	if err := copyNodeDir(mach, app, base, proj, services); err != nil {
+		return nil, err
+	}
+
+	// if noTMSP, we ignore socket and app containers
+	// and just use an in-process null app
+	appMach := proj.AppMachine(mach)
+	if !noTMSP && appMach != mach {
+		// the app lives on a machine of its own
+		if err := startTMCommon(appMach, app, proj); err != nil {
+			return nil, err
+		}
+		if err := copyAppDir(appMach, app, base, proj, services); err != nil {
+			return nil, err
+		}
+	}
+
+	var coreInfo *CoreInfo
+	for _, svc := range services {
+		if noTMSP && svc.OnAppMachine() {
+			continue
+		}
+		if svc.Name == "core" {
+			coreInfo, err = startTMCore(mach, app, proj, nil, randomPorts, noTMSP)
+		} else {
+			err = startService(proj.ServiceMachine(mach, svc), app, proj, svc)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return coreInfo, nil
 }
 
 /*
@@ -126,153 +156,119 @@ func listMachinesFromBase(base string) ([]string, error) {
 }
 */
 
-func startTMCommon(mach, app string) error {
-	args := []string{"ssh", mach, Fmt(`docker run --name %v_tmcommon --entrypoint true tendermint/tmbase`, app)}
+func startTMCommon(mach, app string, proj *Project) error {
+	image := proj.Component(mach, "common").Image
+	args := []string{"ssh", mach, Fmt(`docker run --name %v_tmcommon --entrypoint true %v`, app, image)}
 	if !runProcess("start-tmcommon-"+mach, "docker-machine", args, true) {
 		return errors.New("Failed to start tmcommon on machine " + mach)
 	}
 	return nil
 }
 
-func copyNodeDir(mach, app, base string) error {
-	err := copyToMachine(mach, app, path.Join(base, "data"), "/data/tendermint/data", true)
-	if err != nil {
-		return err
-	}
-	err = copyToMachine(mach, app, path.Join(base, "app"), "/data/tendermint/app", true)
-	if err != nil {
-		return err
-	}
-	err = copyToMachine(mach, app, path.Join(base, "core"), "/data/tendermint/core", true)
-	if err != nil {
-		return err
+// Copies every service's directory, then the machine's own core directory
+func copyNodeDir(mach, app, base string, proj *Project, services []*Service) error {
+	for _, svc := range services {
+		err := copyServiceDir(mach, app, base, proj, svc)
+		if err != nil {
+			return err
+		}
 	}
-	err = copyToMachine(mach, app, path.Join(base, mach, "core", "/."), "/data/tendermint/core", true)
+	err := copyToMachine(mach, app, proj, path.Join(base, mach, "core", "/."), "/data/tendermint/core", true)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-// Starts data service and checks for existence of /data/tendermint/data/data.sock
-func startTMData(mach, app string) error {
-	args := []string{"ssh", mach, Fmt(`docker run --name %v_tmdata --volumes-from %v_tmcommon -d `+
-		`tendermint/tmbase /data/tendermint/data/init.sh`, app, app)}
-	if !runProcess("start-tmdata-"+mach, "docker-machine", args, true) {
-		return errors.New("Failed to start tmdata on machine " + mach)
-	}
-	for i := 1; i < 10; i++ { // TODO configure
-		time.Sleep(time.Duration(i) * time.Second)
-		if checkFileExists(mach, app+"_tmdata", "/data/tendermint/data/data.sock") {
-			return nil
+// Copies the directories of the services on the app machine, which is all a remote app machine needs
+func copyAppDir(mach, app, base string, proj *Project, services []*Service) error {
+	for _, svc := range services {
+		if !svc.OnAppMachine() {
+			continue
+		}
+		err := copyServiceDir(mach, app, base, proj, svc)
+		if err != nil {
+			return err
 		}
 	}
-	return errors.New("Failed to start tmdata on machine " + mach + " (timeout)")
+	return nil
 }
 
-func startTMApp(mach, app string) error {
-	args := []string{"ssh", mach, Fmt(`docker run --name %v_tmapp --volumes-from %v_tmcommon -d `+
-		`tendermint/tmbase /data/tendermint/app/init.sh`, app, app)}
-	if !runProcess("start-tmapp-"+mach, "docker-machine", args, true) {
-		return errors.New("Failed to start tmapp on machine " + mach)
+// Services with prebuilt images may have no directory
+func copyServiceDir(mach, app, base string, proj *Project, svc *Service) error {
+	srcPath := path.Join(base, svc.DirName())
+	if !FileExists(srcPath) && proj.Component(mach, svc.Name).Prebuilt {
+		return nil
 	}
-	return nil
+	return copyToMachine(mach, app, proj, srcPath, path.Join("/data/tendermint", svc.DirName()), true)
 }
 
-func startTMCore(mach, app string, seeds []string, randomPort, noTMSP bool) (*CoreInfo, error) {
+func startTMCore(mach, app string, proj *Project, seeds []string, randomPort, noTMSP bool) (*CoreInfo, error) {
 	portString := "-p 46656:46656 -p 46657:46657"
 	if randomPort {
 		portString = "--publish-all"
 	}
 
-	// IF APP-USE-TCP
-	//   proxyApp := Fmt("tcp://%v_tmapp:46658", app)
-	//   tmspConditions := Fmt(` --link %v_tmapp `, app)
-	// ELSE
-	proxyApp := "unix:///data/tendermint/app/app.sock"
-	tmspConditions := ""
-	// END
-
-	if noTMSP {
-		proxyApp = "nilapp" // in-proc nil app
-		tmspConditions = "" // tmcommon and tmapp weren't started
+	proxyApp := "nilapp" // in-proc nil app
+	tmspConditions := "" // tmcommon and tmapp weren't started
+	if !noTMSP {
+		var err error
+		proxyApp, tmspConditions, err = coreProxyApp(mach, app, proj)
+		if err != nil {
+			return nil, err
+		}
 	}
 	tmRoot := "/data/tendermint/core"
-	args := []string{"ssh", mach, Fmt(`docker run -d %v --name %v_tmcore --volumes-from %v_tmcommon %v`+
-		`-e TMNAME="%v" -e TMSEEDS="%v" -e TMROOT="%v" -e PROXYAPP="%v" `+
-		`tendermint/tmbase /data/tendermint/core/init.sh`,
-		portString, app, app, tmspConditions,
-		eB(mach), eB(strings.Join(seeds, ",")), tmRoot, eB(proxyApp))}
+	cfg := proj.Component(mach, "core")
+	initScript := "/data/tendermint/core/init.sh"
+	if services, err := proj.NodeServices(mach); err == nil {
+		initScript = findService(services, "core").InitScript()
+	}
+	args := []string{"ssh", mach, Fmt(`docker run -d %v --name %v_tmcore --volumes-from %v_tmcommon %v%v`+
+		`-e TMNAME="%v" -e TMSEEDS="%v" -e TMROOT="%v" -e PROXYAPP="%v" -e TMSP="%v" `+
+		`%v`,
+		portString, app, app, tmspConditions, cfg.RunOpts(),
+		eB(mach), eB(strings.Join(seeds, ",")), tmRoot, eB(proxyApp), eB(tmspConnType(proj)),
+		componentCmd(cfg, initScript))}
 	if !runProcess("start-tmcore-"+mach, "docker-machine", args, true) {
 		return nil, errors.New("Failed to start tmcore on machine " + mach)
 	}
 
-	// Give it some time to install and make repo.
-	time.Sleep(time.Second * 10)
-
-	// Get the node's validator info
-	// Need to retry to wait until tendermint is installed
-	for {
-		args = []string{"ssh", mach, Fmt(`docker exec %v_tmcore tendermint show_validator --log_level=error`, app)}
-		output, ok := runProcessGetResult("show-validator-tmcore-"+mach, "docker-machine", args, false)
-		if !ok || output == "" {
-			fmt.Println(Yellow(Fmt("tendermint not yet installed in %v. Waiting...", mach)))
-			time.Sleep(time.Second * 5)
-			continue
-		} else {
-			fmt.Println(Fmt("validator for %v: %v", mach, output))
-
-			// now grab the node's public address and port
-			ip, err := getMachineIP(mach)
-			if err != nil {
-				return nil, err
-			}
-
-			coreInfo := &CoreInfo{
-				Validator: &Validator{
-					ID: mach,
-				},
-			}
+	// Wait until tendermint is installed and running
+	if err := waitReady(mach, app+"_tmcore", proj.ReadyCheck(mach, "core")); err != nil {
+		return nil, err
+	}
 
-			var p2pPort, rpcPort = "46656", "46657"
-			if randomPort {
-				portMap, err := getContainerPortMap(mach, fmt.Sprintf("%v_tmcore", app))
-				if err != nil {
-					return nil, err
-				}
-				p2pPort, ok = portMap["46656"]
-				if !ok {
-					return nil, errors.New("No port map found for p2p port 46656 on mach " + mach)
-				}
-				rpcPort, ok = portMap["46657"]
-				if !ok {
-					return nil, errors.New("No port map found for rpc port 46657 on mach " + mach)
-				}
-			}
-			coreInfo.P2PAddr = fmt.Sprintf("%v:%v", ip, p2pPort)
-			coreInfo.RPCAddr = fmt.Sprintf("%v:%v", ip, rpcPort)
-
-			// get pubkey from rpc endpoint
-			// try a few times in case the rpc server is slow to start
-			var result ctypes.TMResult
-			for i := 0; i < 5; i++ {
-				time.Sleep(time.Second)
-				c := client.NewClientURI(fmt.Sprintf("%s", coreInfo.RPCAddr))
-				if _, err = c.Call("status", nil, &result); err != nil {
-					continue
-				}
-				status := result.(*ctypes.ResultStatus)
-				coreInfo.Validator.PubKey = status.PubKey
-				break
-			}
-			if err != nil {
-				return nil, fmt.Errorf("Error getting PubKey from mach %s on %s: %v", mach, coreInfo.RPCAddr, err)
-			}
+	// now grab the node's public address and port
+	p2pAddr, rpcAddr, err := getCoreAddrs(mach, app)
+	if err != nil {
+		return nil, err
+	}
+	coreInfo := &CoreInfo{
+		Validator: &Validator{
+			ID: mach,
+		},
+		P2PAddr: p2pAddr,
+		RPCAddr: rpcAddr,
+	}
 
-			return coreInfo, nil
+	// get pubkey from rpc endpoint
+	// try a few times in case the rpc server is slow to start
+	for i := 0; i < 5; i++ {
+		time.Sleep(time.Second)
+		var status *ctypes.ResultStatus
+		if status, err = getCoreStatus(coreInfo.RPCAddr); err != nil {
+			continue
 		}
+		coreInfo.Validator.PubKey = status.PubKey
+		break
 	}
-	return nil, nil
+	if err != nil {
+		return nil, fmt.Errorf("Error getting PubKey from mach %s on %s: %v", mach, coreInfo.RPCAddr, err)
+	}
+	fmt.Println(Fmt("validator for %v: %v", mach, coreInfo.Validator.PubKey))
+
+	return coreInfo, nil
 }
 
 func dialSeeds(rpcAddr string, seeds []string) error {
@@ -286,6 +282,52 @@ func dialSeeds(rpcAddr string, seeds []string) error {
 	return nil
 }
 
+// Returns the proxy_app address tmcore on mach uses to reach its app,
+// and any docker run options needed to get there.
+func coreProxyApp(mach, app string, proj *Project) (string, string, error) {
+	appMach := proj.AppMachine(mach)
+	switch proj.ProxyApp {
+	case "", "unix":
+		return "unix:///data/tendermint/app/app.sock", "", nil
+	case "tcp", "grpc":
+		if appMach == mach {
+			return Fmt("tcp://%v_tmapp:46658", app), Fmt(`--link %v_tmapp `, app), nil
+		}
+		ip, err := getMachineIP(appMach)
+		if err != nil {
+			return "", "", err
+		}
+		portMap, err := getContainerPortMap(appMach, Fmt("%v_tmapp", app))
+		if err != nil {
+			return "", "", err
+		}
+		appPort, ok := portMap["46658"]
+		if !ok {
+			return "", "", errors.New("No port map found for app port 46658 on mach " + appMach)
+		}
+		return Fmt("tcp://%v:%v", ip, appPort), "", nil
+	}
+	return "", "", errors.New("Unknown proxy app " + proj.ProxyApp)
+}
+
+// Returns the TMSP connection type for core and app init scripts.
+// Empty means the default socket connection.
+func tmspConnType(proj *Project) string {
+	if proj.ProxyApp == "grpc" {
+		return "grpc"
+	}
+	return ""
+}
+
+// Returns the image and command for a component's docker run.
+// Prebuilt images are run with their own entrypoint instead of init.sh.
+func componentCmd(cfg *ComponentConfig, initScript string) string {
+	if cfg.Prebuilt {
+		return cfg.Image
+	}
+	return cfg.Image + " " + initScript
+}
+
 func getContainerPortMap(mach, container string) (map[string]string, error) {
 	args := []string{"ssh", mach, Fmt(`docker port %v`, container)}
 	output, ok := runProcessGetResult(fmt.Sprintf("get-ports-%v-%v", mach, container), "docker-machine", args, true)
@@ -318,120 +360,198 @@ func cmdRestart(c *cli.Context) {
 	}
 	app := args[0]
 	machines := ParseMachines(c.String("machines"))
+	proj := parseNetworkProject(c, "")
 
-	// Restart TMApp, and TMCore container on each machine
-	var wg sync.WaitGroup
-	for _, mach := range machines {
-		wg.Add(1)
-		go func(mach string) {
-			defer wg.Done()
-			restartTMApp(mach, app)
-			restartTMCore(mach, app)
-		}(mach)
-	}
-	wg.Wait()
-}
-
-func restartTMCore(mach, app string) error {
-	args := []string{"ssh", mach, Fmt(`docker start %v_tmcore`, app)}
-	if !runProcess("restart-tmcore-"+mach, "docker-machine", args, true) {
-		return errors.New("Failed to restart tmcore on machine " + mach)
-	}
-	return nil
-}
-
-func restartTMApp(mach, app string) error {
-	args := []string{"ssh", mach, Fmt(`docker start %v_tmapp`, app)}
-	if !runProcess("restart-tmapp-"+mach, "docker-machine", args, true) {
-		return errors.New("Failed to restart tmapp on machine " + mach)
-	}
-	return nil
+	// Restart each node's services in dependency order
+	results := fanOut(machines, c.Int("parallel"), func(res *machResult) {
+		services, err := proj.NodeServices(res.Mach)
+		if res.Step("services", err) != nil {
+			return
+		}
+		for _, svc := range services {
+			res.Step("restart "+svc.Name, restartService(proj.ServiceMachine(res.Mach, svc), app, svc.Name))
+		}
+	})
+	exitWithResults(results)
 }
 
 //--------------------------------------------------------------------------------
 
 func cmdStop(c *cli.Context) {
 	args := c.Args()
-	if len(args) == 0 {
+	machines, state := parseNetworkMachines(c)
+	app, ok := parseNetworkApp(args, state)
+	if !ok {
 		Exit("stop requires argument for app name")
 	}
-	app := args[0]
-	machines := ParseMachines(c.String("machines"))
+	proj := parseNetworkProject(c, "")
 
-	// Initialize TMCommon, TMData, TMApp, and TMCore container on each machine
-	var wg sync.WaitGroup
-	for _, mach := range machines {
-		wg.Add(1)
-		go func(mach string) {
-			defer wg.Done()
-			stopTMCore(mach, app)
-			stopTMApp(mach, app)
-			stopTMData(mach, app)
-		}(mach)
-	}
-	wg.Wait()
+	// Stop each node's services in reverse dependency order
+	results := fanOut(machines, c.Int("parallel"), func(res *machResult) {
+		services, err := proj.NodeServices(res.Mach)
+		if res.Step("services", err) != nil {
+			return
+		}
+		for i := len(services) - 1; i >= 0; i-- {
+			svc := services[i]
+			res.Step("stop "+svc.Name, stopService(proj.ServiceMachine(res.Mach, svc), app, svc.Name))
+		}
+	})
+	exitWithResults(results)
 }
 
 func stopTMData(mach, app string) error {
-	args := []string{"ssh", mach, Fmt(`docker stop %v_tmdata`, app)}
-	if !runProcess("stop-tmdata-"+mach, "docker-machine", args, true) {
-		return errors.New("Failed to stop tmdata on machine " + mach)
-	}
-	return nil
-}
-
-func stopTMCore(mach, app string) error {
-	args := []string{"ssh", mach, Fmt(`docker stop %v_tmcore`, app)}
-	if !runProcess("stop-tmcore-"+mach, "docker-machine", args, true) {
-		return errors.New("Failed to stop tmcore on machine " + mach)
-	}
-	return nil
+	return stopService(mach, app, "data")
 }
 
 func stopTMApp(mach, app string) error {
-	args := []string{"ssh", mach, Fmt(`docker stop %v_tmapp`, app)}
-	if !runProcess("stop-tmapp-"+mach, "docker-machine", args, true) {
-		return errors.New("Failed to stop tmapp on machine " + mach)
-	}
-	return nil
+	return stopService(mach, app, "app")
 }
 
 //--------------------------------------------------------------------------------
 
 func cmdRm(c *cli.Context) {
 	args := c.Args()
-	if len(args) == 0 {
+	machines, state := parseNetworkMachines(c)
+	app, ok := parseNetworkApp(args, state)
+	if !ok {
 		Exit("rm requires argument for app name")
 	}
-	app := args[0]
-	machines := ParseMachines(c.String("machines"))
 	force := c.Bool("force")
-
-	// Remove TMCommon, TMApp, and TMNode container on each machine
-	var wg sync.WaitGroup
-	for _, mach := range machines {
-		wg.Add(1)
-		go func(mach string) {
-			defer wg.Done()
-			rmContainer(mach, Fmt("%v_tmcommon", app), force)
-			rmContainer(mach, Fmt("%v_tmdata", app), force)
-			rmContainer(mach, Fmt("%v_tmapp", app), force)
-			rmContainer(mach, Fmt("%v_tmcore", app), force)
-		}(mach)
+	proj := parseNetworkProject(c, "")
+
+	// Remove TMCommon and each service's container on each machine
+	results := fanOut(machines, c.Int("parallel"), func(res *machResult) {
+		mach := res.Mach
+		services, err := proj.NodeServices(mach)
+		if res.Step("services", err) != nil {
+			return
+		}
+		res.Step("rm common", rmContainer(mach, Fmt("%v_tmcommon", app), force))
+		if appMach := proj.AppMachine(mach); appMach != mach {
+			res.Step("rm common", rmContainer(appMach, Fmt("%v_tmcommon", app), force))
+		}
+		for _, svc := range services {
+			res.Step("rm "+svc.Name, rmContainer(proj.ServiceMachine(mach, svc), svc.Container(app), force))
+		}
+	})
+	if state != nil {
+		if err := removeFromNetworkState(c.String("network"), succeededMachines(results)); err != nil {
+			fmt.Println(Red("Failed to update network state: " + err.Error()))
+		}
 	}
-	wg.Wait()
+	exitWithResults(results)
 }
 
 func rmContainer(mach, container string, force bool) error {
-	opts := ""
+	cmd := "docker rm " + container
 	if force {
-		opts = "-f"
-	} else {
-		opts = "-y"
+		cmd = "docker rm -f " + container
 	}
-	args := []string{"ssh", mach, Fmt(`docker rm %v %v`, opts, container)}
+	args := []string{"ssh", mach, cmd}
 	if !runProcess(Fmt("rm-%v-%v", container, mach), "docker-machine", args, true) {
 		return errors.New(Fmt("Failed to rm %v on machine %v", container, mach))
 	}
 	return nil
 }
+
+// Get the p2p and rpc addresses of the tmcore container on mach
+func getCoreAddrs(mach, app string) (p2pAddr, rpcAddr string, err error) {
+	ip, err := getMachineIP(mach)
+	if err != nil {
+		return "", "", err
+	}
+	portMap, err := getContainerPortMap(mach, fmt.Sprintf("%v_tmcore", app))
+	if err != nil {
+		return "", "", err
+	}
+	p2pPort, ok := portMap["46656"]
+	if !ok {
+		return "", "", errors.New("No port map found for p2p port 46656 on mach " + mach)
+	}
+	rpcPort, ok := portMap["46657"]
+	if !ok {
+		return "", "", errors.New("No port map found for rpc port 46657 on mach " + mach)
+	}
+	return fmt.Sprintf("%v:%v", ip, p2pPort), fmt.Sprintf("%v:%v", ip, rpcPort), nil
+}
+
+func getCoreStatus(rpcAddr string) (*ctypes.ResultStatus, error) {
+	var result ctypes.TMResult
+	c := client.NewClientURI(fmt.Sprintf("%s", rpcAddr))
+	if _, err := c.Call("status", nil, &result); err != nil {
+		return nil, err
+	}
+	status, ok := result.(*ctypes.ResultStatus)
+	if !ok {
+		return nil, errors.New("Unexpected status result from rpc address " + rpcAddr)
+	}
+	return status, nil
+}
+
+func getBlock(rpcAddr string, height int) (*ctypes.ResultBlock, error) {
+	var result ctypes.TMResult
+	c := client.NewClientURI(fmt.Sprintf("%s", rpcAddr))
+	if _, err := c.Call("block", map[string]interface{}{"height": height}, &result); err != nil {
+		return nil, err
+	}
+	block, ok := result.(*ctypes.ResultBlock)
+	if !ok {
+		return nil, errors.New("Unexpected block result from rpc address " + rpcAddr)
+	}
+	return block, nil
+}
+
+func getValidators(rpcAddr string) (*ctypes.ResultValidators, error) {
+	var result ctypes.TMResult
+	c := client.NewClientURI(fmt.Sprintf("%s", rpcAddr))
+	if _, err := c.Call("validators", nil, &result); err != nil {
+		return nil, err
+	}
+	validators, ok := result.(*ctypes.ResultValidators)
+	if !ok {
+		return nil, errors.New("Unexpected validators result from rpc address " + rpcAddr)
+	}
+	return validators, nil
+}
+
+func getNetInfo(rpcAddr string) (*ctypes.ResultNetInfo, error) {
+	var result ctypes.TMResult
+	c := client.NewClientURI(fmt.Sprintf("%s", rpcAddr))
+	if _, err := c.Call("net_info", nil, &result); err != nil {
+		return nil, err
+	}
+	netInfo, ok := result.(*ctypes.ResultNetInfo)
+	if !ok {
+		return nil, errors.New("Unexpected net_info result from rpc address " + rpcAddr)
+	}
+	return netInfo, nil
+}
+
+func getMempoolSize(rpcAddr string) (int, error) {
+	var result ctypes.TMResult
+	c := client.NewClientURI(fmt.Sprintf("%s", rpcAddr))
+	if _, err := c.Call("num_unconfirmed_txs", nil, &result); err != nil {
+		return 0, err
+	}
+	txs, ok := result.(*ctypes.ResultUnconfirmedTxs)
+	if !ok {
+		return 0, errors.New("Unexpected num_unconfirmed_txs result from rpc address " + rpcAddr)
+	}
+	return txs.N, nil
+}
+
+// Returns the block metas from minHeight to maxHeight, newest first
+func getBlockchainInfo(rpcAddr string, minHeight, maxHeight int) (*ctypes.ResultBlockchainInfo, error) {
+	var result ctypes.TMResult
+	c := client.NewClientURI(fmt.Sprintf("%s", rpcAddr))
+	args := map[string]interface{}{"minHeight": minHeight, "maxHeight": maxHeight}
+	if _, err := c.Call("blockchain", args, &result); err != nil {
+		return nil, err
+	}
+	info, ok := result.(*ctypes.ResultBlockchainInfo)
+	if !ok {
+		return nil, errors.New("Unexpected blockchain result from rpc address " + rpcAddr)
+	}
+	return info, nil
+}
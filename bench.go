@@ -0,0 +1,254 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	. "github.com/tendermint/go-common"
+	client "github.com/tendermint/go-rpc/client"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+
+	"github.com/codegangsta/cli"
+)
+
+// What happened to one broadcast tx
+type benchTx struct {
+	SentAt   time.Time
+	Latency  time.Duration
+	Err      error
+	Rejected bool
+}
+
+// Send txs at a fixed rate to every node's rpc server, then look for them in the blocks.
+// Reports broadcast throughput and latency, rejected txs, and the latency until the txs were committed.
+func cmdBench(c *cli.Context) {
+	args := c.Args()
+	machines, state := parseNetworkMachines(c)
+	app, ok := parseNetworkApp(args, state)
+	if !ok {
+		cli.ShowAppHelp(c)
+		return
+	}
+	rate := c.Int("rate")
+	duration := c.Duration("duration")
+	if rate <= 0 || duration <= 0 {
+		Exit("--rate and --duration must be positive")
+	}
+	if time.Second/time.Duration(rate) == 0 {
+		Exit(Fmt("--rate can be at most %v txs/s", int(time.Second)))
+	}
+	concurrency := c.Int("concurrency")
+	if concurrency < 1 {
+		Exit("--concurrency must be at least 1")
+	}
+	method := "broadcast_tx_" + c.String("mode")
+	if method != "broadcast_tx_async" && method != "broadcast_tx_sync" {
+		Exit("--mode must be async or sync")
+	}
+	template := c.String("tx-template")
+	if _, err := renderTx(template, 0); err != nil {
+		Exit(err.Error())
+	}
+	if !strings.Contains(template, "{i}") && !strings.Contains(template, "{rand}") {
+		Exit("--tx-template needs {i} or {rand}, as nodes reject duplicate txs")
+	}
+
+	rpcAddrs := []string{}
+	for _, mach := range machines {
+		rpcAddr, err := getRPCAddr(state, mach, app)
+		if err != nil {
+			Exit(err.Error())
+		}
+		rpcAddrs = append(rpcAddrs, rpcAddr)
+	}
+	if len(rpcAddrs) == 0 {
+		Exit("No machines to send txs to")
+	}
+	startStatus, err := getCoreStatus(rpcAddrs[0])
+	if err != nil {
+		Exit(err.Error())
+	}
+
+	fmt.Println(Green(Fmt("Sending %v txs/s for %v to %v nodes with %v", rate, duration, len(rpcAddrs), method)))
+	txs := make(map[string]*benchTx)
+	var mtx sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	skipped := 0
+	ticker := time.NewTicker(time.Second / time.Duration(rate))
+	deadline := time.After(duration)
+	start := time.Now()
+LOOP:
+	for i := 0; ; i++ {
+		select {
+		case <-deadline:
+			break LOOP
+		case <-ticker.C:
+		}
+		// Don't pile up requests when the nodes can't keep up
+		select {
+		case sem <- struct{}{}:
+		default:
+			skipped++
+			continue
+		}
+		tx, err := renderTx(template, i)
+		if err != nil {
+			<-sem
+			fmt.Println(Red(err.Error()))
+			break LOOP
+		}
+		sent := &benchTx{SentAt: time.Now()}
+		mtx.Lock()
+		txs[string(tx)] = sent
+		mtx.Unlock()
+		wg.Add(1)
+		go func(rpcAddr string, tx []byte, sent *benchTx) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			rejected, err := broadcastTx(rpcAddr, method, tx)
+			mtx.Lock()
+			sent.Latency = time.Since(sent.SentAt)
+			sent.Rejected = rejected
+			sent.Err = err
+			mtx.Unlock()
+		}(rpcAddrs[i%len(rpcAddrs)], tx, sent)
+	}
+	ticker.Stop()
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	// Give the last txs time to be committed, then find them in the blocks
+	time.Sleep(c.Duration("settle"))
+	commitLatencies, err := findCommittedTxs(rpcAddrs[0], startStatus.LatestBlockHeight+1, txs)
+	if err != nil {
+		fmt.Println(Red("Failed to read blocks: " + err.Error()))
+	}
+
+	printBenchReport(txs, skipped, elapsed, commitLatencies)
+}
+
+// Renders the tx for sequence number i.
+// {i} becomes i, {rand} a random string and {time} the time in nanoseconds.
+// Templates starting with 0x are hex encoded, so there {i} and {time} become
+// 8 bytes of big-endian hex and {rand} 12 random bytes of hex.
+func renderTx(template string, i int) ([]byte, error) {
+	if strings.HasPrefix(template, "0x") {
+		txStr := strings.Replace(template[2:], "{i}", Fmt("%016x", uint64(i)), -1)
+		txStr = strings.Replace(txStr, "{rand}", hex.EncodeToString([]byte(RandStr(12))), -1)
+		txStr = strings.Replace(txStr, "{time}", Fmt("%016x", uint64(time.Now().UnixNano())), -1)
+		tx, err := hex.DecodeString(txStr)
+		if err != nil {
+			return nil, errors.New("Invalid hex tx template " + template)
+		}
+		return tx, nil
+	}
+	txStr := strings.Replace(template, "{i}", strconv.Itoa(i), -1)
+	txStr = strings.Replace(txStr, "{rand}", RandStr(12), -1)
+	txStr = strings.Replace(txStr, "{time}", strconv.FormatInt(time.Now().UnixNano(), 10), -1)
+	return []byte(txStr), nil
+}
+
+// Returns whether the app rejected the tx. Async broadcasts return before the app sees it
+func broadcastTx(rpcAddr, method string, tx []byte) (bool, error) {
+	var result ctypes.TMResult
+	c := client.NewClientURI(fmt.Sprintf("%s", rpcAddr))
+	if _, err := c.Call(method, map[string]interface{}{"tx": tx}, &result); err != nil {
+		return false, err
+	}
+	res, ok := result.(*ctypes.ResultBroadcastTx)
+	if !ok {
+		return false, errors.New("Unexpected broadcast result from rpc address " + rpcAddr)
+	}
+	return res.Code != 0, nil
+}
+
+// Reads the blocks from startHeight to the latest, returning how long after being sent
+// each of our txs was committed, by the block's time
+func findCommittedTxs(rpcAddr string, startHeight int, txs map[string]*benchTx) ([]time.Duration, error) {
+	status, err := getCoreStatus(rpcAddr)
+	if err != nil {
+		return nil, err
+	}
+	latencies := []time.Duration{}
+	for height := startHeight; height <= status.LatestBlockHeight; height++ {
+		result, err := getBlock(rpcAddr, height)
+		if err != nil {
+			return latencies, err
+		}
+		for _, tx := range result.Block.Data.Txs {
+			if sent, ok := txs[string(tx)]; ok {
+				latencies = append(latencies, result.Block.Header.Time.Sub(sent.SentAt))
+			}
+		}
+	}
+	return latencies, nil
+}
+
+func printBenchReport(txs map[string]*benchTx, skipped int, elapsed time.Duration, commitLatencies []time.Duration) {
+	latencies := []time.Duration{}
+	accepted, rejected, failed := 0, 0, 0
+	for _, tx := range txs {
+		switch {
+		case tx.Err != nil:
+			failed++
+		case tx.Rejected:
+			rejected++
+		default:
+			accepted++
+			latencies = append(latencies, tx.Latency)
+		}
+	}
+
+	fmt.Println("")
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, Fmt("Sent\t%v\t(%.1f txs/s)", len(txs), float64(len(txs))/elapsed.Seconds()))
+	fmt.Fprintln(w, Fmt("Accepted\t%v\t(%.1f txs/s)", accepted, float64(accepted)/elapsed.Seconds()))
+	fmt.Fprintln(w, Fmt("Rejected\t%v\t", rejected))
+	fmt.Fprintln(w, Fmt("Failed\t%v\t", failed))
+	fmt.Fprintln(w, Fmt("Skipped\t%v\t(too many requests in flight)", skipped))
+	fmt.Fprintln(w, Fmt("Committed\t%v\t", len(commitLatencies)))
+	w.Flush()
+
+	fmt.Println("")
+	w = tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "LATENCY\tP50\tP90\tP99\tMAX")
+	for _, row := range []struct {
+		name      string
+		latencies []time.Duration
+	}{{"broadcast", latencies}, {"commit", commitLatencies}} {
+		fmt.Fprintln(w, Fmt("%v\t%v\t%v\t%v\t%v", row.name, percentile(row.latencies, 50),
+			percentile(row.latencies, 90), percentile(row.latencies, 99), percentile(row.latencies, 100)))
+	}
+	w.Flush()
+}
+
+// Returns the p-th percentile by the nearest rank, 0 if there are no latencies
+func percentile(latencies []time.Duration, p float64) time.Duration {
+	if len(latencies) == 0 {
+		return 0
+	}
+	sorted := make([]time.Duration, len(latencies))
+	copy(sorted, latencies)
+	sort.Sort(durations(sorted))
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+type durations []time.Duration
+
+func (d durations) Len() int           { return len(d) }
+func (d durations) Less(i, j int) bool { return d[i] < d[j] }
+func (d durations) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	latencies := []time.Duration{}
+	for i := 10; i >= 1; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	for _, c := range []struct {
+		p        float64
+		expected time.Duration
+	}{{50, 5 * time.Millisecond}, {90, 9 * time.Millisecond}, {99, 10 * time.Millisecond}, {0, time.Millisecond}} {
+		if got := percentile(latencies, c.p); got != c.expected {
+			t.Errorf("Expected p%v to be %v, got %v", c.p, c.expected, got)
+		}
+	}
+	if got := percentile(nil, 50); got != 0 {
+		t.Errorf("Expected 0 without latencies, got %v", got)
+	}
+}
+
+func TestRenderTx(t *testing.T) {
+	tx, err := renderTx("0x{i}ff", 18)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if string(tx) != "\x00\x00\x00\x00\x00\x00\x00\x12\xff" {
+		t.Errorf("Unexpected tx %X", tx)
+	}
+	if tx, _ := renderTx("tx-{i}", 3); string(tx) != "tx-3" {
+		t.Errorf("Unexpected tx %v", string(tx))
+	}
+	for i := 0; i < 12; i++ {
+		if tx, err := renderTx("0x{i}", i); err != nil || len(tx) != 8 {
+			t.Errorf("Expected 8 bytes for %v, got %X, %v", i, tx, err)
+		}
+	}
+	if tx, err := renderTx("0x01{rand}{time}", 0); err != nil || tx[0] != 0x01 {
+		t.Errorf("Expected a hex tx with random bytes and the time, got %X, %v", tx, err)
+	}
+	if _, err := renderTx("0x{i}f", 1); err == nil {
+		t.Error("Expected error for odd length hex")
+	}
+}
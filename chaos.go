@@ -0,0 +1,205 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"strings"
+
+	. "github.com/tendermint/go-common"
+
+	"github.com/codegangsta/cli"
+)
+
+// Rules live in their own iptables chain, so heal removes only what we added
+const chaosChain = "MINTNET"
+
+//--------------------------------------------------------------------------------
+
+// Cut the network into groups of machines that can't reach each other.
+// Each tmcore drops traffic to and from the machines of the other groups.
+func cmdChaosPartition(c *cli.Context) {
+	args := c.Args()
+	state := parseNetworkState(c)
+	app, ok := parseNetworkApp(args, state)
+	if !ok {
+		cli.ShowAppHelp(c)
+		return
+	}
+	groups, err := parseGroups(c.String("groups"))
+	if err != nil {
+		Exit(err.Error())
+	}
+	machines := []string{}
+	for _, group := range groups {
+		machines = append(machines, group...)
+	}
+
+	// Look up every machine's ip first, as each needs the others'
+	ips := make(map[string]string)
+	for _, mach := range machines {
+		ip, err := chaosMachineIP(state, mach)
+		if err != nil {
+			Exit(err.Error())
+		}
+		ips[mach] = ip
+	}
+	blocked := partitionBlocks(groups, ips)
+
+	results := fanOut(machines, c.Int("parallel"), func(res *machResult) {
+		script := partitionScript(blocked[res.Mach])
+		res.Step("partition", chaosExec(res.Mach, app, c.String("net-image"), script))
+	})
+	exitWithResults(results)
+}
+
+// Remove partitions and netem from the selected machines
+func cmdChaosHeal(c *cli.Context) {
+	args := c.Args()
+	machines, state := parseNetworkMachines(c)
+	app, ok := parseNetworkApp(args, state)
+	if !ok {
+		cli.ShowAppHelp(c)
+		return
+	}
+	script := healScript(c.String("dev"))
+	results := fanOut(machines, c.Int("parallel"), func(res *machResult) {
+		res.Step("heal", chaosExec(res.Mach, app, c.String("net-image"), script))
+	})
+	exitWithResults(results)
+}
+
+// Add latency and packet loss to the selected tmcores' traffic
+func cmdChaosNetem(c *cli.Context) {
+	args := c.Args()
+	machines, state := parseNetworkMachines(c)
+	app, ok := parseNetworkApp(args, state)
+	if !ok {
+		cli.ShowAppHelp(c)
+		return
+	}
+	script, err := netemScript(c.String("dev"), c.String("delay"), c.String("jitter"), c.String("loss"))
+	if err != nil {
+		Exit(err.Error())
+	}
+	results := fanOut(machines, c.Int("parallel"), func(res *machResult) {
+		res.Step("netem", chaosExec(res.Mach, app, c.String("net-image"), script))
+	})
+	exitWithResults(results)
+}
+
+//--------------------------------------------------------------------------------
+
+// Runs script in the network namespace of mach's tmcore.
+// It runs in a helper container with NET_ADMIN and the tools, which tmcore has neither of.
+func chaosExec(mach, app, netImage, script string) error {
+	return dockerCmd(mach, []string{"run", "--rm", Fmt("--net=container:%v_tmcore", app),
+		"--cap-add=NET_ADMIN", netImage, "sh", "-c", shellQuote(script)})
+}
+
+// Nodes reach each other through their machines' ips
+func chaosMachineIP(state *NetworkState, mach string) (string, error) {
+	if node := nodeFromState(state, mach); node != nil && node.Core != nil {
+		if host, _, err := net.SplitHostPort(node.Core.P2PAddr); err == nil {
+			return host, nil
+		}
+	}
+	return getMachineIP(mach)
+}
+
+// Takes groups like "mach[1-2];mach[3-4]" and returns the machines of each
+func parseGroups(groupsStr string) ([][]string, error) {
+	groups := [][]string{}
+	seen := make(map[string]bool)
+	for _, groupStr := range strings.Split(groupsStr, ";") {
+		groupStr = strings.TrimSpace(groupStr)
+		if groupStr == "" {
+			continue
+		}
+		group, err := parseMachines(groupStr)
+		if err != nil {
+			return nil, err
+		}
+		for _, mach := range group {
+			if seen[mach] {
+				return nil, errors.New(Fmt("Machine %v is in more than one group", mach))
+			}
+			seen[mach] = true
+		}
+		groups = append(groups, group)
+	}
+	if len(groups) < 2 {
+		return nil, errors.New("A partition needs at least two groups, e.g. --groups=\"mach[1-2];mach[3-4]\"")
+	}
+	return groups, nil
+}
+
+// Returns the ips each machine must block: those of the machines in other groups
+func partitionBlocks(groups [][]string, ips map[string]string) map[string][]string {
+	blocked := make(map[string][]string)
+	for i, group := range groups {
+		for _, mach := range group {
+			for j, other := range groups {
+				if i == j {
+					continue
+				}
+				for _, otherMach := range other {
+					blocked[mach] = append(blocked[mach], ips[otherMach])
+				}
+			}
+		}
+	}
+	return blocked
+}
+
+// Replaces our chain with one dropping traffic to and from ips
+func partitionScript(ips []string) string {
+	lines := []string{
+		Fmt("iptables -N %v 2>/dev/null || true", chaosChain),
+		Fmt("iptables -F %v", chaosChain),
+		Fmt("iptables -C INPUT -j %v 2>/dev/null || iptables -I INPUT -j %v", chaosChain, chaosChain),
+		Fmt("iptables -C OUTPUT -j %v 2>/dev/null || iptables -I OUTPUT -j %v", chaosChain, chaosChain),
+	}
+	for _, ip := range ips {
+		lines = append(lines,
+			Fmt("iptables -A %v -s %v -j DROP", chaosChain, ip),
+			Fmt("iptables -A %v -d %v -j DROP", chaosChain, ip))
+	}
+	return strings.Join(lines, " && ")
+}
+
+// Removes our chain and any netem qdisc. Either may not exist
+func healScript(dev string) string {
+	lines := []string{
+		Fmt("iptables -D INPUT -j %v 2>/dev/null", chaosChain),
+		Fmt("iptables -D OUTPUT -j %v 2>/dev/null", chaosChain),
+		Fmt("iptables -F %v 2>/dev/null", chaosChain),
+		Fmt("iptables -X %v 2>/dev/null", chaosChain),
+		Fmt("tc qdisc del dev %v root 2>/dev/null", dev),
+		"true",
+	}
+	return strings.Join(lines, "; ")
+}
+
+// Returns a tc command adding delay, jitter and loss, e.g. "200ms", "50ms" and "5%"
+func netemScript(dev, delay, jitter, loss string) (string, error) {
+	if delay == "" && loss == "" {
+		return "", errors.New("netem needs --delay or --loss")
+	}
+	if jitter != "" && delay == "" {
+		return "", errors.New("--jitter needs --delay")
+	}
+	opts := ""
+	for _, opt := range []struct{ name, value string }{{"delay", delay}, {"", jitter}, {"loss", loss}} {
+		if opt.value == "" {
+			continue
+		}
+		if strings.ContainsAny(opt.value, " ;&|'\"$`") {
+			return "", errors.New("Invalid netem value " + opt.value)
+		}
+		if opt.name != "" {
+			opts += " " + opt.name
+		}
+		opts += " " + opt.value
+	}
+	return Fmt("tc qdisc replace dev %v root netem%v", dev, opts), nil
+}
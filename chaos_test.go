@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPartitionBlocks(t *testing.T) {
+	groups, err := parseGroups("mach[1-2];mach3")
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	ips := map[string]string{"mach1": "10.0.0.1", "mach2": "10.0.0.2", "mach3": "10.0.0.3"}
+	blocked := partitionBlocks(groups, ips)
+	if strings.Join(blocked["mach1"], ",") != "10.0.0.3" {
+		t.Errorf("Unexpected blocked ips for mach1: %v", blocked["mach1"])
+	}
+	if strings.Join(blocked["mach3"], ",") != "10.0.0.1,10.0.0.2" {
+		t.Errorf("Unexpected blocked ips for mach3: %v", blocked["mach3"])
+	}
+
+	if _, err := parseGroups("mach[1-2]"); err == nil {
+		t.Error("Expected error for a single group")
+	}
+	if _, err := parseGroups("mach[1-2];mach[2-3]"); err == nil {
+		t.Error("Expected error for a machine in two groups")
+	}
+}
+
+func TestNetemScript(t *testing.T) {
+	script, err := netemScript("eth0", "200ms", "50ms", "5%")
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	expected := "tc qdisc replace dev eth0 root netem delay 200ms 50ms loss 5%"
+	if script != expected {
+		t.Errorf("Expected %v but got %v", expected, script)
+	}
+	if _, err := netemScript("eth0", "", "", ""); err == nil {
+		t.Error("Expected error without delay or loss")
+	}
+	if _, err := netemScript("eth0", "1ms; reboot", "", ""); err == nil {
+		t.Error("Expected error for an unsafe value")
+	}
+}
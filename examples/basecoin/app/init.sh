@@ -2,4 +2,4 @@
 
 go get github.com/tendermint/basecoin/cmd/...
 
-basecoin --address="unix:///data/tendermint/app/app.sock" --eyes="unix:///data/tendermint/data/data.sock" --genesis="/data/tendermint/app/genesis.json"
+basecoin --address="${APPADDR:-unix:///data/tendermint/app/app.sock}" --eyes="unix:///data/tendermint/data/data.sock" --genesis="/data/tendermint/app/genesis.json"
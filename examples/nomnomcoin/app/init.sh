@@ -6,4 +6,4 @@ git clone https://github.com/tendermint/nomnomcoin.git
 cd nomnomcoin
 npm install .
 
-node app.js --eyes="unix:///data/tendermint/data/data.sock" --addr="unix:///data/tendermint/app/app.sock"
+node app.js --eyes="unix:///data/tendermint/data/data.sock" --addr="${APPADDR:-unix:///data/tendermint/app/app.sock}"
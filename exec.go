@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+
+	. "github.com/tendermint/go-common"
+
+	"github.com/codegangsta/cli"
+)
+
+//--------------------------------------------------------------------------------
+
+type execResult struct {
+	Mach     string
+	Stdout   string
+	Stderr   string
+	ExitCode int
+	Err      error
+}
+
+// Run a command inside a component's container on every machine.
+// Prints each machine's output and the results, and exits non-zero if any machine failed.
+func cmdExec(c *cli.Context) {
+	args := c.Args()
+	if len(args) < 2 {
+		cli.ShowAppHelp(c)
+		return
+	}
+	app := args[0]
+	cmdArgs := args[1:]
+	if cmdArgs[0] == "--" {
+		cmdArgs = cmdArgs[1:]
+	}
+	if len(cmdArgs) == 0 {
+		Exit("exec requires a command to run")
+	}
+	machines := ParseMachines(c.String("machines"))
+	component := c.String("component")
+	proj := parseNetworkProject(c, "")
+
+	// Each machine writes only its own slot
+	execResults := make([]*execResult, len(machines))
+	results := fanOut(machines, c.Int("parallel"), func(res *machResult) {
+		execRes := execInContainer(res.Mach, app, proj, component, cmdArgs)
+		execResults[res.Index] = execRes
+		err := execRes.Err
+		if err == nil && execRes.ExitCode != 0 {
+			err = fmt.Errorf("exit code %v", execRes.ExitCode)
+		}
+		res.Step("exec", err)
+	})
+
+	for _, execRes := range execResults {
+		fmt.Println(Blue(Fmt("=== %v (exit code %v)", execRes.Mach, execRes.ExitCode)))
+		if execRes.Stdout != "" {
+			fmt.Print(execRes.Stdout)
+		}
+		if execRes.Stderr != "" {
+			fmt.Print(Red(execRes.Stderr))
+		}
+	}
+	exitWithResults(results)
+}
+
+// Runs cmdArgs in the component's container for the node on mach.
+// Every argument is quoted, so it reaches the container unchanged.
+func execInContainer(mach, app string, proj *Project, component string, cmdArgs []string) *execResult {
+	res := &execResult{Mach: mach}
+	services, err := proj.NodeServices(mach)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	svc := findService(services, component)
+	if svc == nil {
+		res.Err = fmt.Errorf("Unknown component %v on machine %v", component, mach)
+		return res
+	}
+	args := []string{"ssh", proj.ServiceMachine(mach, svc), Fmt("docker exec %v %v", svc.Container(app), shellJoin(cmdArgs))}
+	res.Stdout, res.Stderr, res.ExitCode, res.Err = runProcessGetOutput("exec-"+mach, "docker-machine", args, false)
+	return res
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	. "github.com/tendermint/go-common"
+)
+
+// The outcome of one step of an operation on a machine
+type stepResult struct {
+	Name string
+	Err  error
+}
+
+// Collects the steps run on one machine
+type machResult struct {
+	Mach  string
+	Index int // position in the machines list
+	Steps []stepResult
+}
+
+// Records the outcome of a step and passes err through
+func (res *machResult) Step(name string, err error) error {
+	res.Steps = append(res.Steps, stepResult{name, err})
+	return err
+}
+
+func (res *machResult) Failed() bool {
+	for _, step := range res.Steps {
+		if step.Err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Runs fn for every machine concurrently, at most parallel at a time (0 means no limit).
+// Each call records its steps on its own result, so no locking is needed.
+// Results are returned in the order of machines.
+func fanOut(machines []string, parallel int, fn func(res *machResult)) []*machResult {
+	results := make([]*machResult, len(machines))
+	if parallel <= 0 || parallel > len(machines) {
+		parallel = len(machines)
+	}
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, mach := range machines {
+		results[i] = &machResult{Mach: mach, Index: i}
+		wg.Add(1)
+		go func(res *machResult) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			fn(res)
+		}(results[i])
+	}
+	wg.Wait()
+	return results
+}
+
+// Prints a matrix of machines by steps, followed by any errors.
+// Exits non-zero if a step failed on any machine.
+func exitWithResults(results []*machResult) {
+	// columns are steps in the order first seen
+	steps := []string{}
+	seen := make(map[string]bool)
+	for _, res := range results {
+		for _, step := range res.Steps {
+			if !seen[step.Name] {
+				seen[step.Name] = true
+				steps = append(steps, step.Name)
+			}
+		}
+	}
+
+	fmt.Println("")
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "MACHINE\t"+strings.ToUpper(strings.Join(steps, "\t")))
+	failed := 0
+	for _, res := range results {
+		cells := make([]string, len(steps))
+		for i, name := range steps {
+			cells[i] = "-"
+			for _, step := range res.Steps {
+				if step.Name != name {
+					continue
+				}
+				if step.Err != nil {
+					cells[i] = "FAIL"
+				} else if cells[i] == "-" {
+					cells[i] = "ok"
+				}
+			}
+		}
+		fmt.Fprintln(w, res.Mach+"\t"+strings.Join(cells, "\t"))
+		if res.Failed() {
+			failed++
+		}
+	}
+	w.Flush()
+
+	if failed == 0 {
+		fmt.Println(Green(Fmt("Succeeded on all %v machines", len(results))))
+		return
+	}
+	fmt.Println("")
+	for _, res := range results {
+		for _, step := range res.Steps {
+			if step.Err != nil {
+				fmt.Println(Red(Fmt("%v: %v: %v", res.Mach, step.Name, step.Err)))
+			}
+		}
+	}
+	Exit(Fmt("Failed on %v of %v machines", failed, len(results)))
+}
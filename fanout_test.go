@@ -0,0 +1,44 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFanOutParallel(t *testing.T) {
+	machines, _ := parseMachines("mach[1-10]")
+	var mtx sync.Mutex
+	running, maxRunning := 0, 0
+	results := fanOut(machines, 3, func(res *machResult) {
+		mtx.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mtx.Unlock()
+		time.Sleep(time.Millisecond * 10)
+		mtx.Lock()
+		running--
+		mtx.Unlock()
+
+		var err error
+		if res.Mach == "mach4" {
+			err = errors.New("failed")
+		}
+		res.Step("create", err)
+	})
+
+	if maxRunning > 3 {
+		t.Errorf("Expected at most 3 machines at once, got %v", maxRunning)
+	}
+	for i, res := range results {
+		if res.Mach != machines[i] || res.Index != i {
+			t.Errorf("Expected result %v for %v, got %v", i, machines[i], res.Mach)
+		}
+		if res.Failed() != (res.Mach == "mach4") {
+			t.Errorf("Unexpected failure state for %v", res.Mach)
+		}
+	}
+}
@@ -2,6 +2,10 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	. "github.com/tendermint/go-common"
 
@@ -10,7 +14,43 @@ import (
 
 //--------------------------------------------------------------------------------
 func cmdInfo(c *cli.Context) {
-	cli.ShowAppHelp(c)
+	if c.String("network") == "" {
+		cli.ShowAppHelp(c)
+		return
+	}
+	_, state := parseNetworkMachines(c)
+	fmt.Println(Fmt("Network %v on chain %v, started %v", state.App, state.ChainID, state.StartTime.Format(time.RFC3339)))
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "MACHINE\tP2P\tRPC\tCONTAINERS")
+	for _, node := range state.Nodes {
+		p2pAddr, rpcAddr := "-", "-"
+		if node.Core != nil {
+			p2pAddr, rpcAddr = node.Core.P2PAddr, node.Core.RPCAddr
+		}
+		fmt.Fprintln(w, Fmt("%v\t%v\t%v\t%v", node.Mach, p2pAddr, rpcAddr, len(node.Containers)))
+	}
+	w.Flush()
+}
+
+// Print the addresses of the network's nodes, comma separated, e.g. for --seeds
+func cmdInfoAddrs(c *cli.Context, kind string) {
+	machines, state := parseNetworkMachines(c)
+	if state == nil {
+		Exit("info " + kind + " requires --network")
+	}
+	addrs := []string{}
+	for _, mach := range machines {
+		node := state.Node(mach)
+		if node == nil || node.Core == nil {
+			Exit("No node on machine " + mach + " in the network state")
+		}
+		if kind == "seeds" {
+			addrs = append(addrs, node.Core.P2PAddr)
+		} else {
+			addrs = append(addrs, node.Core.RPCAddr)
+		}
+	}
+	fmt.Println(strings.Join(addrs, ","))
 }
 
 //--------------------------------------------------------------------------------
@@ -23,13 +63,71 @@ func cmdPorts(c *cli.Context) {
 	}
 	appName := args[0]
 	machines := ParseMachines(c.GlobalString("machines"))
+	var state *NetworkState
+	if c.String("network") != "" {
+		machines, state = parseNetworkMachines(c)
+	}
 	for _, mach := range machines {
-		portMap, err := getContainerPortMap(mach, fmt.Sprintf("%v_tmcore", appName))
-		if err != nil {
-			Exit(err.Error())
+		var portMap map[string]string
+		if node := nodeFromState(state, mach); node != nil {
+			portMap = node.PortMap()
+		} else {
+			var err error
+			portMap, err = getContainerPortMap(mach, fmt.Sprintf("%v_tmcore", appName))
+			if err != nil {
+				Exit(err.Error())
+			}
 		}
 		fmt.Println("Machine", mach)
 		fmt.Println(portMap)
 		fmt.Println("")
 	}
 }
+
+//--------------------------------------------------------------------------------
+
+// Print each node's latest block height.
+// With --network the nodes' rpc addresses come from the network state.
+func cmdStatus(c *cli.Context) {
+	args := c.Args()
+	machines, state := parseNetworkMachines(c)
+	app := ""
+	if len(args) == 1 {
+		app = args[0]
+	} else if state != nil {
+		app = state.App
+	} else {
+		cli.ShowAppHelp(c)
+		return
+	}
+
+	heights := make([]int, len(machines))
+	rpcAddrs := make([]string, len(machines))
+	results := fanOut(machines, c.Int("parallel"), func(res *machResult) {
+		rpcAddr, err := getRPCAddr(state, res.Mach, app)
+		if res.Step("status", err) != nil {
+			return
+		}
+		rpcAddrs[res.Index] = rpcAddr
+		status, err := getCoreStatus(rpcAddr)
+		if res.Step("status", err) == nil {
+			heights[res.Index] = status.LatestBlockHeight
+		}
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "MACHINE\tHEIGHT\tRPC")
+	failed := 0
+	for i, res := range results {
+		height := fmt.Sprintf("%v", heights[i])
+		if res.Failed() {
+			height = "down"
+			failed++
+		}
+		fmt.Fprintln(w, Fmt("%v\t%v\t%v", res.Mach, height, rpcAddrs[i]))
+	}
+	w.Flush()
+	if failed > 0 {
+		Exit(Fmt("%v of %v nodes are down", failed, len(results)))
+	}
+}
@@ -2,8 +2,10 @@ package main
 
 import (
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"path"
+	"strings"
 	"time"
 
 	"github.com/codegangsta/cli"
@@ -72,6 +74,22 @@ func cmdChainInit(c *cli.Context) {
 	machines := ParseMachines(c.GlobalString("machines"))
 	app := c.String("app")
 
+	// Test only: duplicates share a validator's key and double sign
+	duplicates, err := parseDuplicateValidators(c.String("duplicate-validator"), machines)
+	if err != nil {
+		Exit(err.Error())
+	}
+	if len(duplicates) > 0 && !c.Bool("allow-double-sign") {
+		Exit("--duplicate-validator makes validators double sign and is for testing only. Add --allow-double-sign to confirm")
+	}
+	// Duplicates aren't validators of their own
+	valMachines := []string{}
+	for _, mach := range machines {
+		if _, ok := duplicates[mach]; !ok {
+			valMachines = append(valMachines, mach)
+		}
+	}
+
 	var appHash []byte
 	appHashString := c.String("app-hash")
 	if appHashString != "" {
@@ -86,7 +104,7 @@ func cmdChainInit(c *cli.Context) {
 		}
 	}
 
-	err := initDataDirectory(base)
+	err = initDataDirectory(base)
 	if err != nil {
 		Exit(err.Error())
 	}
@@ -99,7 +117,7 @@ func cmdChainInit(c *cli.Context) {
 		Exit(err.Error())
 	}
 
-	genVals := make([]tmtypes.GenesisValidator, len(machines))
+	genVals := make([]tmtypes.GenesisValidator, len(valMachines))
 
 	//var valSetID string
 	valSetDir := c.String("validator-set")
@@ -114,24 +132,21 @@ func cmdChainInit(c *cli.Context) {
 		}
 		vals := valSet.Validators
 
-		if len(machines) != len(vals) {
-			Exit(fmt.Sprintf("Validator set size must match number of machines. Got %d validators, %d machines", len(vals), len(machines)))
+		if len(valMachines) != len(vals) {
+			Exit(fmt.Sprintf("Validator set size must match number of machines. Got %d validators, %d machines", len(vals), len(valMachines)))
 		}
 
 		for i, val := range vals {
 
 			// build the directory
-			mach := machines[i]
+			mach := valMachines[i]
 			err := initMachCoreDirectory(base, mach)
 			if err != nil {
 				Exit(err.Error())
 			}
 
 			// overwrite the priv validator
-			privValFile := path.Join(valSetDir, val.ID, "priv_validator.json")
-			privVal := tmtypes.LoadPrivValidator(privValFile)
-			privVal.SetFile(path.Join(base, mach, "core", "priv_validator.json"))
-			privVal.Save()
+			copyPrivValidator(path.Join(valSetDir, val.ID, "priv_validator.json"), base, mach)
 		}
 
 		// copy the vals into genVals
@@ -146,7 +161,7 @@ func cmdChainInit(c *cli.Context) {
 		//valSetID = ValSetAnon
 
 		// Initialize core dir and priv_validator.json's
-		for i, mach := range machines {
+		for i, mach := range valMachines {
 			err := initMachCoreDirectory(base, mach)
 			if err != nil {
 				Exit(err.Error())
@@ -162,6 +177,20 @@ func cmdChainInit(c *cli.Context) {
 		}
 	}
 
+	// Give each duplicate its validator's key
+	for _, mach := range machines {
+		valMach, ok := duplicates[mach]
+		if !ok {
+			continue
+		}
+		err := initMachCoreDirectory(base, mach)
+		if err != nil {
+			Exit(err.Error())
+		}
+		copyPrivValidator(path.Join(base, valMach, "core", "priv_validator.json"), base, mach)
+		fmt.Println(Red(Fmt("TEST ONLY: %v shares the validator key of %v and will double sign", mach, valMach)))
+	}
+
 	// Generate genesis doc from generated validators
 	genDoc := &tmtypes.GenesisDoc{
 		GenesisTime: time.Now(),
@@ -178,6 +207,49 @@ func cmdChainInit(c *cli.Context) {
 	fmt.Println(Fmt("Successfully initialized %v node directories", len(machines)))
 }
 
+// Overwrites mach's priv_validator.json with the one in privValFile
+func copyPrivValidator(privValFile, base, mach string) {
+	privVal := tmtypes.LoadPrivValidator(privValFile)
+	privVal.SetFile(path.Join(base, mach, "core", "priv_validator.json"))
+	privVal.Save()
+}
+
+// Takes pairs like "mach1:mach5,mach2:mach6" and returns each duplicate's validator machine,
+// e.g. mach5 gets mach1's key
+func parseDuplicateValidators(pairsStr string, machines []string) (map[string]string, error) {
+	duplicates := make(map[string]string)
+	if pairsStr == "" {
+		return duplicates, nil
+	}
+	isMach := make(map[string]bool)
+	for _, mach := range machines {
+		isMach[mach] = true
+	}
+	for _, pair := range strings.Split(pairsStr, ",") {
+		machs := strings.Split(strings.TrimSpace(pair), ":")
+		if len(machs) != 2 || machs[0] == "" || machs[1] == "" {
+			return nil, errors.New("Expected validator:duplicate, got " + pair)
+		}
+		valMach, dupMach := machs[0], machs[1]
+		if !isMach[valMach] || !isMach[dupMach] {
+			return nil, errors.New(Fmt("Both %v and %v must be in --machines", valMach, dupMach))
+		}
+		if valMach == dupMach {
+			return nil, errors.New("A machine cannot duplicate itself: " + valMach)
+		}
+		if _, ok := duplicates[dupMach]; ok {
+			return nil, errors.New(dupMach + " duplicates more than one validator")
+		}
+		duplicates[dupMach] = valMach
+	}
+	for dupMach, valMach := range duplicates {
+		if _, ok := duplicates[valMach]; ok {
+			return nil, errors.New(Fmt("%v duplicates %v, which is itself a duplicate", dupMach, valMach))
+		}
+	}
+	return duplicates, nil
+}
+
 // Initialize per-machine core directory
 func initMachCoreDirectory(base, mach string) error {
 	dir := path.Join(base, mach, "core")
@@ -254,7 +326,7 @@ git clone https://github.com/tendermint/nomnomcoin.git
 cd nomnomcoin
 npm install .
 
-node app.js --addr="unix:///data/tendermint/app/app.sock" --eyes="unix:///data/tendermint/data/data.sock"`)
+node app.js --addr="${APPADDR:-unix:///data/tendermint/app/app.sock}" --eyes="unix:///data/tendermint/data/data.sock"`)
 	} else {
 		var err error
 		scriptBytes, err = ReadFile(app)
@@ -295,7 +367,7 @@ git fetch origin $BRANCH
 git checkout $BRANCH
 make install
 
-tendermint node --seeds="$TMSEEDS" --moniker="$TMNAME" --proxy_app="$PROXYAPP"`)
+tendermint node --seeds="$TMSEEDS" --moniker="$TMNAME" --proxy_app="$PROXYAPP" ${TMSP:+--tmsp="$TMSP"}`)
 
 	err = WriteFile(path.Join(dir, "init.sh"), scriptBytes, 0777)
 	return err
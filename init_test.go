@@ -0,0 +1,22 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseDuplicateValidators(t *testing.T) {
+	machines := []string{"mach1", "mach2", "mach5", "mach6"}
+	duplicates, err := parseDuplicateValidators("mach1:mach5,mach2:mach6", machines)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if len(duplicates) != 2 || duplicates["mach5"] != "mach1" || duplicates["mach6"] != "mach2" {
+		t.Errorf("Unexpected duplicates %v", duplicates)
+	}
+
+	for _, pairs := range []string{"mach1", "mach1:mach7", "mach1:mach1", "mach1:mach5,mach2:mach5", "mach1:mach5,mach5:mach6"} {
+		if _, err := parseDuplicateValidators(pairs, machines); err == nil {
+			t.Errorf("Expected error for %v", pairs)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	. "github.com/tendermint/go-common"
+
+	"github.com/codegangsta/cli"
+)
+
+//--------------------------------------------------------------------------------
+
+// List the machines backing a network, existing or not
+func cmdMachinesLs(c *cli.Context) {
+	proj := ParseProject(c, "")
+	desired, _, err := machineCreateArgs(c, proj)
+	if err != nil {
+		Exit(err.Error())
+	}
+	existing, err := networkMachines(c.String("prefix"), desired)
+	if err != nil {
+		Exit(err.Error())
+	}
+
+	isDesired := make(map[string]bool)
+	for _, mach := range desired {
+		isDesired[mach] = true
+	}
+	exists := make(map[string]bool)
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "MACHINE\tSTATE\tDRIVER\tDESIRED")
+	for _, info := range existing {
+		exists[info.Name] = true
+		fmt.Fprintln(w, Fmt("%v\t%v\t%v\t%v", info.Name, info.State, info.Driver, yesNo(isDesired[info.Name])))
+	}
+	for _, mach := range desired {
+		if !exists[mach] {
+			fmt.Fprintln(w, Fmt("%v\tMissing\t-\tyes", mach))
+		}
+	}
+	w.Flush()
+}
+
+// Compare the machines backing a network to its definition.
+// With --reconcile, create the missing machines and destroy the extra ones,
+// which without --prefix needs --yes as they're only matched by name.
+func cmdMachinesDiff(c *cli.Context) {
+	proj := ParseProject(c, "")
+	desired, machArgs, err := machineCreateArgs(c, proj)
+	if err != nil {
+		Exit(err.Error())
+	}
+	existing, err := networkMachines(c.String("prefix"), desired)
+	if err != nil {
+		Exit(err.Error())
+	}
+	existingNames := []string{}
+	for _, info := range existing {
+		existingNames = append(existingNames, info.Name)
+	}
+
+	missing, extra := diffMachines(desired, existingNames)
+	for _, mach := range missing {
+		fmt.Println(Green("+ " + mach))
+	}
+	for _, mach := range extra {
+		fmt.Println(Red("- " + mach))
+	}
+	if len(missing) == 0 && len(extra) == 0 {
+		fmt.Println(Green(Fmt("All %v machines exist", len(desired))))
+		return
+	}
+
+	if !c.Bool("reconcile") {
+		Exit(Fmt("%v machines missing, %v extra. Use --reconcile to create and destroy them", len(missing), len(extra)))
+	}
+	// Without a prefix the extras were only guessed by name, and may be someone else's
+	kept := 0
+	if c.String("prefix") == "" && !c.Bool("yes") {
+		kept, extra = len(extra), nil
+	}
+	results := createMachines(missing, machArgs, c.Int("parallel"))
+	results = append(results, fanOut(extra, c.Int("parallel"), func(res *machResult) {
+		res.Step("destroy", removeMachine(res.Mach))
+	})...)
+	if len(results) > 0 {
+		exitWithResults(results)
+	}
+	if kept > 0 {
+		Exit(Fmt("Not destroying %v machines matched by name only. Use --prefix, or --yes to destroy them", kept))
+	}
+}
+
+// Returns the existing machines of the network: those starting with prefix if given,
+// otherwise those named like a desired machine with another number, e.g. mach7 for mach[1-4]
+func networkMachines(prefix string, desired []string) ([]machineInfo, error) {
+	if prefix != "" {
+		return listMachines(prefix)
+	}
+	all, err := listMachines("")
+	if err != nil {
+		return nil, err
+	}
+	stems := make(map[string]bool)
+	for _, mach := range desired {
+		stems[machineStem(mach)] = true
+	}
+	matched := []machineInfo{}
+	for _, info := range all {
+		if stems[machineStem(info.Name)] {
+			matched = append(matched, info)
+		}
+	}
+	return matched, nil
+}
+
+// Strips the trailing number of a machine name
+func machineStem(mach string) string {
+	return strings.TrimRight(mach, "0123456789")
+}
+
+// Returns the desired machines that don't exist, and the existing ones that aren't desired
+func diffMachines(desired, existing []string) (missing, extra []string) {
+	isDesired := make(map[string]bool)
+	for _, mach := range desired {
+		isDesired[mach] = true
+	}
+	exists := make(map[string]bool)
+	for _, mach := range existing {
+		exists[mach] = true
+		if !isDesired[mach] {
+			extra = append(extra, mach)
+		}
+	}
+	for _, mach := range desired {
+		if !exists[mach] {
+			missing = append(missing, mach)
+		}
+	}
+	sort.Strings(extra)
+	return missing, extra
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffMachines(t *testing.T) {
+	missing, extra := diffMachines([]string{"mach1", "mach2", "mach3"}, []string{"mach5", "mach2", "mach4"})
+	if strings.Join(missing, ",") != "mach1,mach3" {
+		t.Errorf("Unexpected missing machines %v", missing)
+	}
+	if strings.Join(extra, ",") != "mach4,mach5" {
+		t.Errorf("Unexpected extra machines %v", extra)
+	}
+	if stem := machineStem("us-east12"); stem != "us-east" {
+		t.Errorf("Expected us-east, got %v", stem)
+	}
+}
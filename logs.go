@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	. "github.com/tendermint/go-common"
+	pcm "github.com/tendermint/go-process"
+
+	"github.com/codegangsta/cli"
+)
+
+//--------------------------------------------------------------------------------
+
+// Stream docker logs from every selected container, each line tagged by machine and component.
+// With --dir, logs are written to <dir>/<mach>/<component>.log instead.
+func cmdLogs(c *cli.Context) {
+	args := c.Args()
+	if len(args) != 1 {
+		cli.ShowAppHelp(c)
+		return
+	}
+	app := args[0]
+	machines := ParseMachines(c.String("machines"))
+	proj := parseNetworkProject(c, "")
+	dir := c.String("dir")
+
+	components := map[string]bool{}
+	if componentsStr := c.String("component"); componentsStr != "" {
+		for _, name := range strings.Split(componentsStr, ",") {
+			components[strings.TrimSpace(name)] = true
+		}
+	}
+
+	opts := ""
+	if c.Bool("follow") {
+		opts += "--follow "
+	}
+	if since := c.String("since"); since != "" {
+		opts += Fmt(`--since="%v" `, eB(since))
+	}
+	if tail := c.String("tail"); tail != "" {
+		opts += Fmt(`--tail="%v" `, eB(tail))
+	}
+
+	var wg sync.WaitGroup
+	var stdoutMtx sync.Mutex
+	for _, mach := range machines {
+		services, err := proj.NodeServices(mach)
+		if err != nil {
+			Exit(err.Error())
+		}
+		for _, svc := range services {
+			if len(components) > 0 && !components[svc.Name] {
+				continue
+			}
+			wg.Add(1)
+			go func(mach string, svc *Service) {
+				defer wg.Done()
+				var out io.WriteCloser
+				if dir != "" {
+					if err := EnsureDir(path.Join(dir, mach), 0777); err != nil {
+						fmt.Println(Red(err.Error()))
+						return
+					}
+					file, err := os.Create(path.Join(dir, mach, svc.Name+".log"))
+					if err != nil {
+						fmt.Println(Red(err.Error()))
+						return
+					}
+					out = file
+				} else {
+					out = newTaggedWriter(Fmt("%v/%v", mach, svc.Name), &stdoutMtx)
+				}
+				err := streamLogs(proj.ServiceMachine(mach, svc), svc.Container(app), opts, out)
+				if err != nil {
+					stdoutMtx.Lock()
+					fmt.Println(Red(err.Error()))
+					stdoutMtx.Unlock()
+				}
+			}(mach, svc)
+		}
+	}
+	wg.Wait()
+
+	if dir != "" {
+		fmt.Println(Green("Wrote logs to " + dir))
+	}
+}
+
+// Runs docker logs for container on mach, copying its output to out until it exits
+func streamLogs(mach, container, opts string, out io.WriteCloser) error {
+	defer out.Close()
+	args := []string{"ssh", mach, Fmt(`docker logs %v%v 2>&1`, opts, container)}
+	proc, err := pcm.StartProcess(Fmt("logs-%v-%v", container, mach), "docker-machine", args, nil, out)
+	if err != nil {
+		return err
+	}
+	<-proc.WaitCh
+	if !proc.ExitState.Success() {
+		return errors.New(Fmt("Failed to get logs of %v on machine %v", container, mach))
+	}
+	return nil
+}
+
+//--------------------------------------------------------------------------------
+
+// Prefixes every complete line with a tag before printing it.
+// Lines from different writers sharing mtx never interleave.
+type taggedWriter struct {
+	tag string
+	mtx *sync.Mutex
+	buf bytes.Buffer
+}
+
+func newTaggedWriter(tag string, mtx *sync.Mutex) *taggedWriter {
+	return &taggedWriter{tag: tag, mtx: mtx}
+}
+
+func (tw *taggedWriter) Write(p []byte) (int, error) {
+	tw.buf.Write(p)
+	for {
+		idx := bytes.IndexByte(tw.buf.Bytes(), '\n')
+		if idx == -1 {
+			break
+		}
+		line := string(tw.buf.Next(idx + 1))
+		tw.printLine(strings.TrimRight(line, "\r\n"))
+	}
+	return len(p), nil
+}
+
+// Prints whatever is left of an unterminated last line
+func (tw *taggedWriter) Close() error {
+	if tw.buf.Len() > 0 {
+		tw.printLine(tw.buf.String())
+		tw.buf.Reset()
+	}
+	return nil
+}
+
+func (tw *taggedWriter) printLine(line string) {
+	tw.mtx.Lock()
+	defer tw.mtx.Unlock()
+	fmt.Println(Cyan("["+tw.tag+"]"), line)
+}
@@ -2,9 +2,8 @@ package main
 
 import (
 	"errors"
-	"fmt"
+	"os"
 	"strings"
-	"sync"
 
 	. "github.com/tendermint/go-common"
 
@@ -17,15 +16,10 @@ func cmdDocker(c *cli.Context) {
 	args := c.Args()
 	machines := ParseMachines(c.String("machines"))
 
-	var wg sync.WaitGroup
-	for _, mach := range machines {
-		wg.Add(1)
-		go func(mach string) {
-			defer wg.Done()
-			dockerCmd(mach, args)
-		}(mach)
-	}
-	wg.Wait()
+	results := fanOut(machines, c.Int("parallel"), func(res *machResult) {
+		res.Step("docker", dockerCmd(res.Mach, args))
+	})
+	exitWithResults(results)
 }
 
 func dockerCmd(mach string, args []string) error {
@@ -39,31 +33,79 @@ func dockerCmd(mach string, args []string) error {
 //--------------------------------------------------------------------------------
 
 func cmdCreate(c *cli.Context) {
-	args := c.Args()
-	machines := ParseMachines(c.String("machines"))
-
-	errs := createMachines(machines, args)
-	if len(errs) > 0 {
-		Exit(Fmt("There were %v errors", len(errs)))
-	} else {
-		fmt.Println(Fmt("Successfully created %v machines", len(machines)))
+	proj := ParseProject(c, "")
+	machines, machArgs, err := machineCreateArgs(c, proj)
+	if err != nil {
+		Exit(err.Error())
 	}
+
+	results := createMachines(machines, machArgs, c.Int("parallel"))
+	exitWithResults(results)
 }
 
-func createMachines(machines []string, args []string) (errs []error) {
-	var wg sync.WaitGroup
-	for _, mach := range machines {
-		wg.Add(1)
-		go func(mach string) {
-			defer wg.Done()
-			err := createMachine(args, mach)
-			if err != nil {
-				errs = append(errs, err)
+// Returns the machines to create and the docker-machine create args of each.
+// With --template, the template is used for --machines.
+// Otherwise machines come from the project's create groups, limited to --machines if given,
+// falling back to --machines without a template.
+// Args after -- are added for every machine.
+func machineCreateArgs(c *cli.Context, proj *Project) ([]string, map[string][]string, error) {
+	extraArgs := c.Args()
+	machArgs := make(map[string][]string)
+
+	if tmplName := c.String("template"); tmplName != "" {
+		tmpl, ok := proj.Templates[tmplName]
+		if !ok {
+			return nil, nil, errors.New("Unknown template " + tmplName)
+		}
+		tmplArgs, err := tmpl.CreateArgs()
+		if err != nil {
+			return nil, nil, err
+		}
+		args := append(tmplArgs, extraArgs...)
+		machines := ParseMachines(c.String("machines"))
+		for _, mach := range machines {
+			machArgs[mach] = args
+		}
+		return machines, machArgs, nil
+	}
+
+	if len(proj.Create) == 0 {
+		machines := ParseMachines(c.String("machines"))
+		for _, mach := range machines {
+			machArgs[mach] = extraArgs
+		}
+		return machines, machArgs, nil
+	}
+
+	allMachines, templates, err := proj.CreateMachines()
+	if err != nil {
+		return nil, nil, err
+	}
+	machines := allMachines
+	if c.IsSet("machines") {
+		machines = []string{}
+		for _, mach := range ParseMachines(c.String("machines")) {
+			if _, ok := templates[mach]; !ok {
+				return nil, nil, errors.New("Machine " + mach + " is not in any create group of the project")
 			}
-		}(mach)
+			machines = append(machines, mach)
+		}
+	}
+	for _, mach := range machines {
+		tmplArgs, err := templates[mach].CreateArgs()
+		if err != nil {
+			return nil, nil, err
+		}
+		machArgs[mach] = append(tmplArgs, extraArgs...)
 	}
-	wg.Wait()
-	return errs
+	return machines, machArgs, nil
+}
+
+// Creates machines, at most parallel at a time so cloud providers don't rate limit us
+func createMachines(machines []string, machArgs map[string][]string, parallel int) []*machResult {
+	return fanOut(machines, parallel, func(res *machResult) {
+		res.Step("create", createMachine(machArgs[res.Mach], res.Mach))
+	})
 }
 
 func createMachine(args []string, mach string) error {
@@ -75,26 +117,73 @@ func createMachine(args []string, mach string) error {
 	return nil
 }
 
+// docker-machine flags for the template fields of each driver
+var driverFlags = map[string]struct{ region, size, image, tags string }{
+	"digitalocean": {"--digitalocean-region", "--digitalocean-size", "--digitalocean-image", "--digitalocean-tags"},
+	"amazonec2":    {"--amazonec2-region", "--amazonec2-instance-type", "--amazonec2-ami", "--amazonec2-tags"},
+	"google":       {"--google-zone", "--google-machine-type", "--google-machine-image", "--google-tags"},
+	"azure":        {"--azure-location", "--azure-size", "--azure-image", ""},
+}
+
+// Returns the docker-machine create args for the template
+func (tmpl *MachineTemplate) CreateArgs() ([]string, error) {
+	if tmpl.Driver == "" {
+		return nil, errors.New("Machine template without a driver")
+	}
+	args := []string{"--driver=" + tmpl.Driver}
+	flags, ok := driverFlags[tmpl.Driver]
+	if !ok && (tmpl.Region != "" || tmpl.Size != "" || tmpl.Image != "" || len(tmpl.Tags) > 0) {
+		return nil, errors.New(Fmt("Driver %v only supports args in templates", tmpl.Driver))
+	}
+	addFlag := func(field, flag, value string) error {
+		if value == "" {
+			return nil
+		}
+		if flag == "" {
+			return errors.New(Fmt("Driver %v does not support %v in templates", tmpl.Driver, field))
+		}
+		args = append(args, flag+"="+value)
+		return nil
+	}
+	tags := strings.Join(tmpl.Tags, ",")
+	if tmpl.Driver == "amazonec2" && len(tmpl.Tags) > 0 {
+		// amazonec2 takes key,value pairs; plain tags become keys with empty values
+		pairs := []string{}
+		for _, tag := range tmpl.Tags {
+			kv := strings.SplitN(tag, "=", 2)
+			if len(kv) == 1 {
+				kv = append(kv, "")
+			}
+			pairs = append(pairs, kv...)
+		}
+		tags = strings.Join(pairs, ",")
+	}
+	for _, err := range []error{
+		addFlag("region", flags.region, tmpl.Region),
+		addFlag("size", flags.size, tmpl.Size),
+		addFlag("image", flags.image, tmpl.Image),
+		addFlag("tags", flags.tags, tags),
+	} {
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, arg := range tmpl.Args {
+		args = append(args, os.ExpandEnv(arg))
+	}
+	return args, nil
+}
+
 //--------------------------------------------------------------------------------
 
 func cmdDestroy(c *cli.Context) {
 	machines := ParseMachines(c.String("machines"))
 
 	// Destroy each machine.
-	var wg sync.WaitGroup
-	for _, mach := range machines {
-		wg.Add(1)
-		go func(mach string) {
-			defer wg.Done()
-			err := removeMachine(mach)
-			if err != nil {
-				fmt.Println(Red(err.Error()))
-			}
-		}(mach)
-	}
-	wg.Wait()
-
-	fmt.Println("Success!")
+	results := fanOut(machines, c.Int("parallel"), func(res *machResult) {
+		res.Step("destroy", removeMachine(res.Mach))
+	})
+	exitWithResults(results)
 }
 
 //--------------------------------------------------------------------------------
@@ -103,28 +192,14 @@ func cmdProvision(c *cli.Context) {
 	args := c.Args()
 	machines := ParseMachines(c.String("machines"))
 
-	errs := provisionMachines(machines, args)
-	if len(errs) > 0 {
-		Exit(Fmt("There were %v errors", len(errs)))
-	} else {
-		fmt.Println(Fmt("Successfully created %v machines", len(machines)))
-	}
+	results := provisionMachines(machines, args, c.Int("parallel"))
+	exitWithResults(results)
 }
 
-func provisionMachines(machines []string, args []string) (errs []error) {
-	var wg sync.WaitGroup
-	for _, mach := range machines {
-		wg.Add(1)
-		go func(mach string) {
-			defer wg.Done()
-			err := provisionMachine(args, mach)
-			if err != nil {
-				errs = append(errs, err)
-			}
-		}(mach)
-	}
-	wg.Wait()
-	return errs
+func provisionMachines(machines []string, args []string, parallel int) []*machResult {
+	return fanOut(machines, parallel, func(res *machResult) {
+		res.Step("provision", provisionMachine(args, res.Mach))
+	})
 }
 
 func provisionMachine(args []string, mach string) error {
@@ -158,10 +233,17 @@ func removeMachine(mach string) error {
 	return nil
 }
 
-// List machine names that match prefix
-func listMachines(prefix string) ([]string, error) {
-	args := []string{"ls", "--quiet"}
-	output, ok := runProcessGetResult("list-machines", "docker-machine", args, true)
+// A machine known to docker-machine
+type machineInfo struct {
+	Name   string
+	State  string
+	Driver string
+}
+
+// List machines whose names start with prefix
+func listMachines(prefix string) ([]machineInfo, error) {
+	args := []string{"ls", "--format", "{{.Name}}\t{{.State}}\t{{.DriverName}}"}
+	output, ok := runProcessGetResult("list-machines", "docker-machine", args, false)
 	if !ok {
 		return nil, errors.New("Failed to list machines")
 	}
@@ -169,12 +251,16 @@ func listMachines(prefix string) ([]string, error) {
 	if len(output) == 0 {
 		return nil, nil
 	}
-	machines := strings.Split(output, "\n")
-	matched := []string{}
-	for _, mach := range machines {
-		if strings.HasPrefix(mach, prefix+"-") {
-			matched = append(matched, mach)
+	matched := []machineInfo{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(strings.TrimSpace(line), "\t")
+		if fields[0] == "" || !strings.HasPrefix(fields[0], prefix) {
+			continue
+		}
+		for len(fields) < 3 {
+			fields = append(fields, "")
 		}
+		matched = append(matched, machineInfo{fields[0], fields[1], fields[2]})
 	}
 	return matched, nil
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"os"
+	"time"
 
 	"github.com/codegangsta/cli"
 )
@@ -14,6 +15,56 @@ var (
 		Value: "mach[1-4]",
 		Usage: "Comma separated list of machine names",
 	}
+	parallelFlag = cli.IntFlag{
+		Name:  "parallel",
+		Value: 0,
+		Usage: "Maximum number of machines to work on at once, 0 for no limit",
+	}
+	projectFlag = cli.StringFlag{
+		Name:  "project",
+		Value: "",
+		Usage: "Path to the project file, defaults to baseDir/" + ProjectFileName,
+	}
+	baseFlag = cli.StringFlag{
+		Name:  "base",
+		Value: "",
+		Usage: "The network's baseDir, for its " + ProjectFileName + " (defaults to the directory of --network)",
+	}
+	proxyAppFlag = cli.StringFlag{
+		Name:  "proxy-app",
+		Value: "",
+		Usage: "How tmcore connects to the app: unix, tcp, or grpc (defaults to the project file, or unix)",
+	}
+	templateFlag = cli.StringFlag{
+		Name:  "template",
+		Value: "",
+		Usage: "Machine template from the project file to create --machines with",
+	}
+	machPrefixFlag = cli.StringFlag{
+		Name:  "prefix",
+		Value: "",
+		Usage: "Machines whose names start with prefix belong to the network (defaults to the desired names without their numbers)",
+	}
+	networkFlag = cli.StringFlag{
+		Name:  "network",
+		Value: "",
+		Usage: "Path to the " + NetworkFileName + " written by start, instead of looking up --machines",
+	}
+	netImageFlag = cli.StringFlag{
+		Name:  "net-image",
+		Value: defaultProbeImage,
+		Usage: "Image with iptables and tc, run in the network namespace of tmcore",
+	}
+	netDevFlag = cli.StringFlag{
+		Name:  "dev",
+		Value: "eth0",
+		Usage: "Network interface of the tmcore container",
+	}
+	imageFlag = cli.StringSliceFlag{
+		Name:  "image",
+		Value: &cli.StringSlice{},
+		Usage: "Docker image for a component, e.g. core=tendermint/tmbase (overrides the project file)",
+	}
 )
 
 func main() {
@@ -29,16 +80,47 @@ func main() {
 			Action: func(c *cli.Context) {
 				cmdInfo(c)
 			},
-			Flags: []cli.Flag{machFlag},
+			Flags: []cli.Flag{machFlag, networkFlag},
 			Subcommands: []cli.Command{
 				{
 					Name:      "port",
 					Usage:     "Print container port mapping",
 					ArgsUsage: "[appName]",
+					Flags:     []cli.Flag{networkFlag},
 					Action: func(c *cli.Context) {
 						cmdPorts(c)
 					},
 				},
+				{
+					Name:  "seeds",
+					Usage: "Print the nodes' p2p addresses from the network state, comma separated",
+					Flags: []cli.Flag{machFlag, networkFlag},
+					Action: func(c *cli.Context) {
+						cmdInfoAddrs(c, "seeds")
+					},
+				},
+				{
+					Name:  "rpc",
+					Usage: "Print the nodes' rpc addresses from the network state, comma separated",
+					Flags: []cli.Flag{machFlag, networkFlag},
+					Action: func(c *cli.Context) {
+						cmdInfoAddrs(c, "rpc")
+					},
+				},
+			},
+		},
+
+		{
+			Name:      "status",
+			Usage:     "Print each node's latest block height",
+			ArgsUsage: "[appName]",
+			Flags: []cli.Flag{
+				machFlag,
+				parallelFlag,
+				networkFlag,
+			},
+			Action: func(c *cli.Context) {
+				cmdStatus(c)
 			},
 		},
 
@@ -76,6 +158,15 @@ func main() {
 							Value: "",
 							Usage: "Specify the app's initial hash. Prefix with 0x if it's hex",
 						},
+						cli.StringFlag{
+							Name:  "duplicate-validator",
+							Value: "",
+							Usage: "TEST ONLY: give a machine another's validator key so they double sign, e.g. mach1:mach5",
+						},
+						cli.BoolFlag{
+							Name:  "allow-double-sign",
+							Usage: "Confirm --duplicate-validator",
+						},
 					},
 				},
 				{
@@ -102,6 +193,9 @@ func main() {
 			ArgsUsage: "",
 			Flags: []cli.Flag{
 				machFlag,
+				parallelFlag,
+				projectFlag,
+				templateFlag,
 			},
 			Action: func(c *cli.Context) {
 				cmdCreate(c)
@@ -114,18 +208,61 @@ func main() {
 			ArgsUsage: "",
 			Flags: []cli.Flag{
 				machFlag,
+				parallelFlag,
 			},
 			Action: func(c *cli.Context) {
 				cmdProvision(c)
 			},
 		},
 
+		{
+			Name:  "machines",
+			Usage: "Compare the machines backing a network to its definition in --machines or the project file",
+			Subcommands: []cli.Command{
+				{
+					Name:  "ls",
+					Usage: "List the network's machines and whether they exist",
+					Flags: []cli.Flag{
+						machFlag,
+						projectFlag,
+						machPrefixFlag,
+					},
+					Action: func(c *cli.Context) {
+						cmdMachinesLs(c)
+					},
+				},
+				{
+					Name:  "diff",
+					Usage: "Show missing and extra machines. Use -- to pass args through to docker-machine create",
+					Flags: []cli.Flag{
+						machFlag,
+						parallelFlag,
+						projectFlag,
+						templateFlag,
+						machPrefixFlag,
+						cli.BoolFlag{
+							Name:  "reconcile",
+							Usage: "Create missing machines and destroy extra ones",
+						},
+						cli.BoolFlag{
+							Name:  "yes",
+							Usage: "Destroy extra machines matched by name without --prefix",
+						},
+					},
+					Action: func(c *cli.Context) {
+						cmdMachinesDiff(c)
+					},
+				},
+			},
+		},
+
 		{
 			Name:      "destroy",
 			Usage:     "Destroy a Tendermint network",
 			ArgsUsage: "",
 			Flags: []cli.Flag{
 				machFlag,
+				parallelFlag,
 			},
 			Action: func(c *cli.Context) {
 				cmdDestroy(c)
@@ -151,18 +288,104 @@ func main() {
 					Usage: "Use a null, in-process app",
 				},
 				machFlag,
+				parallelFlag,
+				projectFlag,
+				imageFlag,
+				proxyAppFlag,
 			},
 			Action: func(c *cli.Context) {
 				cmdStart(c)
 			},
 		},
 
+		{
+			Name:      "upgrade",
+			Usage:     "Push a new init.sh and recreate a component's containers, one machine at a time",
+			ArgsUsage: "[appName] [baseDir]",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "component",
+					Value: "core",
+					Usage: "Service to upgrade: core, app, data, or one from the project file",
+				},
+				cli.DurationFlag{
+					Name:  "timeout",
+					Value: 5 * time.Minute,
+					Usage: "How long to wait for each upgraded node to serve rpc and catch up",
+				},
+				cli.BoolFlag{
+					Name:  "publish-all,P",
+					Usage: "Publish all exposed ports to random ports",
+				},
+				cli.BoolFlag{
+					Name:  "no-tmsp",
+					Usage: "Use a null, in-process app",
+				},
+				machFlag,
+				projectFlag,
+				imageFlag,
+				proxyAppFlag,
+			},
+			Action: func(c *cli.Context) {
+				cmdUpgrade(c)
+			},
+		},
+
+		{
+			Name:      "scale-out",
+			Usage:     "Add --machines as new nodes of a running network, using its existing genesis.json",
+			ArgsUsage: "[appName] [baseDir]",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "publish-all,P",
+					Usage: "Publish all exposed ports to random ports",
+				},
+				cli.BoolFlag{
+					Name:  "no-tmsp",
+					Usage: "Use a null, in-process app",
+				},
+				machFlag,
+				parallelFlag,
+				projectFlag,
+				imageFlag,
+				proxyAppFlag,
+			},
+			Action: func(c *cli.Context) {
+				cmdScaleOut(c)
+			},
+		},
+
+		{
+			Name:      "scale-in",
+			Usage:     "Stop and remove the nodes on --machines, checking the voting power left online in baseDir's genesis.json",
+			ArgsUsage: "[appName] [baseDir]",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "destroy",
+					Usage: "Destroy the machines too",
+				},
+				cli.BoolFlag{
+					Name:  "force",
+					Usage: "Remove validators even if the chain would halt",
+				},
+				machFlag,
+				parallelFlag,
+				projectFlag,
+			},
+			Action: func(c *cli.Context) {
+				cmdScaleIn(c)
+			},
+		},
+
 		{
 			Name:      "restart",
 			Usage:     "Re start a stopped blockchain application",
 			ArgsUsage: "[appName]",
 			Flags: []cli.Flag{
 				machFlag,
+				parallelFlag,
+				projectFlag,
+				baseFlag,
 			},
 			Action: func(c *cli.Context) {
 				cmdRestart(c)
@@ -175,6 +398,10 @@ func main() {
 			ArgsUsage: "[appName]",
 			Flags: []cli.Flag{
 				machFlag,
+				parallelFlag,
+				projectFlag,
+				baseFlag,
+				networkFlag,
 			},
 			Action: func(c *cli.Context) {
 				cmdStop(c)
@@ -190,17 +417,340 @@ func main() {
 					Usage: "Force stop app if already running",
 				},
 				machFlag,
+				parallelFlag,
+				projectFlag,
+				baseFlag,
+				networkFlag,
 			},
 			Action: func(c *cli.Context) {
 				cmdRm(c)
 			},
 		},
 
+		{
+			Name:      "logs",
+			Usage:     "Stream container logs from all machines, tagged by machine and component",
+			ArgsUsage: "[appName]",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "component",
+					Value: "",
+					Usage: "Comma separated list of components to show, e.g. core,app. Defaults to all",
+				},
+				cli.BoolFlag{
+					Name:  "follow,f",
+					Usage: "Keep streaming new log output",
+				},
+				cli.StringFlag{
+					Name:  "since",
+					Value: "",
+					Usage: "Only show logs since a timestamp or relative time, e.g. 10m",
+				},
+				cli.StringFlag{
+					Name:  "tail",
+					Value: "",
+					Usage: "Number of lines to show from the end of each log",
+				},
+				cli.StringFlag{
+					Name:  "dir",
+					Value: "",
+					Usage: "Write logs to dir/<machine>/<component>.log instead of stdout",
+				},
+				machFlag,
+				projectFlag,
+				baseFlag,
+			},
+			Action: func(c *cli.Context) {
+				cmdLogs(c)
+			},
+		},
+
+		{
+			Name:      "exec",
+			Usage:     "Run a command inside a component's container on all machines. Use -- before the command",
+			ArgsUsage: "[appName] -- [command...]",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "component",
+					Value: "core",
+					Usage: "Component whose container runs the command",
+				},
+				machFlag,
+				parallelFlag,
+				projectFlag,
+				baseFlag,
+			},
+			Action: func(c *cli.Context) {
+				cmdExec(c)
+			},
+		},
+
+		{
+			Name:      "bench",
+			Usage:     "Send txs to every node at a fixed rate and report throughput and latencies",
+			ArgsUsage: "[appName]",
+			Flags: []cli.Flag{
+				cli.IntFlag{
+					Name:  "rate",
+					Value: 100,
+					Usage: "Txs per second, across all nodes",
+				},
+				cli.DurationFlag{
+					Name:  "duration",
+					Value: time.Minute,
+					Usage: "How long to send txs",
+				},
+				cli.StringFlag{
+					Name:  "tx-template",
+					Value: "bench-{i}-{rand}",
+					Usage: "Tx to send, where {i} is its number, {rand} a random string and {time} the time. Prefix with 0x if it's hex, where the placeholders become hex too",
+				},
+				cli.StringFlag{
+					Name:  "mode",
+					Value: "async",
+					Usage: "Broadcast with broadcast_tx_async or broadcast_tx_sync",
+				},
+				cli.IntFlag{
+					Name:  "concurrency",
+					Value: 100,
+					Usage: "Most requests in flight. Txs due while at the limit are skipped",
+				},
+				cli.DurationFlag{
+					Name:  "settle",
+					Value: 10 * time.Second,
+					Usage: "How long to wait for the last txs to be committed",
+				},
+				machFlag,
+				networkFlag,
+			},
+			Action: func(c *cli.Context) {
+				cmdBench(c)
+			},
+		},
+
+		{
+			Name:      "report",
+			Usage:     "Report block intervals, txs, rounds and validator participation over a range of heights",
+			ArgsUsage: "[appName]",
+			Flags: []cli.Flag{
+				cli.IntFlag{
+					Name:  "from-height",
+					Value: 1,
+					Usage: "First height to report on",
+				},
+				cli.IntFlag{
+					Name:  "to-height",
+					Value: 0,
+					Usage: "Last height to report on, defaults to the latest",
+				},
+				cli.StringFlag{
+					Name:  "format",
+					Value: "markdown",
+					Usage: "markdown or json",
+				},
+				cli.StringFlag{
+					Name:  "out",
+					Value: "",
+					Usage: "Write the report to this file instead of stdout",
+				},
+				cli.StringFlag{
+					Name:  "validator-set",
+					Value: "",
+					Usage: "Path to the validator set, to name validators by their id",
+				},
+				machFlag,
+				networkFlag,
+			},
+			Action: func(c *cli.Context) {
+				cmdReport(c)
+			},
+		},
+
+		{
+			Name:      "verify",
+			Usage:     "Find the first height where the nodes' block or app hashes differ",
+			ArgsUsage: "[appName]",
+			Flags: []cli.Flag{
+				cli.IntFlag{
+					Name:  "from-height",
+					Value: 1,
+					Usage: "First height to compare",
+				},
+				cli.IntFlag{
+					Name:  "to-height",
+					Value: 0,
+					Usage: "Last height to compare, defaults to the lowest latest height of the nodes",
+				},
+				machFlag,
+				networkFlag,
+			},
+			Action: func(c *cli.Context) {
+				cmdVerify(c)
+			},
+		},
+
+		{
+			Name:      "alert",
+			Usage:     "Follow every node's new blocks and fire hooks when alert rules match",
+			ArgsUsage: "[appName]",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "rules",
+					Value: "alerts.yaml",
+					Usage: "File with the alert rules and hooks",
+				},
+				machFlag,
+				networkFlag,
+			},
+			Action: func(c *cli.Context) {
+				cmdAlert(c)
+			},
+		},
+
+		{
+			Name:      "top",
+			Usage:     "Show a refreshing table of every node's containers, height, peers and mempool",
+			ArgsUsage: "[appName]",
+			Flags: []cli.Flag{
+				cli.DurationFlag{
+					Name:  "interval",
+					Value: 2 * time.Second,
+					Usage: "How often to refresh",
+				},
+				cli.BoolFlag{
+					Name:  "no-containers",
+					Usage: "Don't inspect the containers, which needs an ssh per machine",
+				},
+				machFlag,
+				projectFlag,
+				baseFlag,
+				networkFlag,
+			},
+			Action: func(c *cli.Context) {
+				cmdTop(c)
+			},
+		},
+
+		{
+			Name:      "monitor",
+			Usage:     "Serve Prometheus metrics of every node's height, peers and containers",
+			ArgsUsage: "[appName]",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "listen",
+					Value: ":9100",
+					Usage: "Address to serve /metrics on",
+				},
+				cli.DurationFlag{
+					Name:  "interval",
+					Value: 10 * time.Second,
+					Usage: "How often to poll the nodes",
+				},
+				machFlag,
+				projectFlag,
+				baseFlag,
+				networkFlag,
+			},
+			Action: func(c *cli.Context) {
+				cmdMonitor(c)
+			},
+		},
+
+		{
+			Name:  "chaos",
+			Usage: "Inject faults into the tmcore containers of a running network",
+			Subcommands: []cli.Command{
+				{
+					Name:      "partition",
+					Usage:     "Split the --groups of machines so they can't reach each other",
+					ArgsUsage: "[appName]",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "groups",
+							Value: "",
+							Usage: "Semicolon separated groups of machines, e.g. mach[1-2];mach[3-4]",
+						},
+						netImageFlag,
+						parallelFlag,
+						networkFlag,
+					},
+					Action: func(c *cli.Context) {
+						cmdChaosPartition(c)
+					},
+				},
+				{
+					Name:      "heal",
+					Usage:     "Remove partitions and netem from --machines",
+					ArgsUsage: "[appName]",
+					Flags: []cli.Flag{
+						netDevFlag,
+						netImageFlag,
+						machFlag,
+						parallelFlag,
+						networkFlag,
+					},
+					Action: func(c *cli.Context) {
+						cmdChaosHeal(c)
+					},
+				},
+				{
+					Name:      "netem",
+					Usage:     "Add latency and packet loss to the traffic of --machines",
+					ArgsUsage: "[appName]",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "delay",
+							Value: "",
+							Usage: "Added latency, e.g. 200ms",
+						},
+						cli.StringFlag{
+							Name:  "jitter",
+							Value: "",
+							Usage: "Variation of the latency, e.g. 50ms",
+						},
+						cli.StringFlag{
+							Name:  "loss",
+							Value: "",
+							Usage: "Share of packets dropped, e.g. 5%",
+						},
+						netDevFlag,
+						netImageFlag,
+						machFlag,
+						parallelFlag,
+						networkFlag,
+					},
+					Action: func(c *cli.Context) {
+						cmdChaosNetem(c)
+					},
+				},
+				{
+					Name:      "run",
+					Usage:     "Run the timed fault injection steps of a scenario, failing if the chain halts too long",
+					ArgsUsage: "[scenario.yaml]",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "timeline",
+							Value: "",
+							Usage: "Write the timeline with the observed heights to this JSON file",
+						},
+						machFlag,
+						projectFlag,
+						baseFlag,
+						networkFlag,
+					},
+					Action: func(c *cli.Context) {
+						cmdChaosRun(c)
+					},
+				},
+			},
+		},
+
 		{
 			Name:  "docker",
 			Usage: "Execute a docker command on all machines",
 			Flags: []cli.Flag{
 				machFlag,
+				parallelFlag,
 			},
 			Action: func(c *cli.Context) {
 				cmdDocker(c)
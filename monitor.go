@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/tendermint/go-common"
+
+	"github.com/codegangsta/cli"
+)
+
+// Serve Prometheus metrics of every node, polled in the background
+func cmdMonitor(c *cli.Context) {
+	args := c.Args()
+	machines, state := parseNetworkMachines(c)
+	app, ok := parseNetworkApp(args, state)
+	if !ok {
+		cli.ShowAppHelp(c)
+		return
+	}
+	proj := parseNetworkProject(c, "")
+	watcher := newNodeWatcher(app, proj, state, machines)
+	interval := c.Duration("interval")
+
+	var mtx sync.Mutex
+	var snapshots []*nodeSnapshot
+	go func() {
+		for {
+			polled := watcher.poll(true)
+			mtx.Lock()
+			snapshots = polled
+			mtx.Unlock()
+			time.Sleep(interval)
+		}
+	}()
+
+	http.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		polled := snapshots
+		mtx.Unlock()
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		writeMetrics(w, app, polled)
+	})
+	listen := c.String("listen")
+	fmt.Println(Green(Fmt("Serving metrics of %v nodes on %v/metrics", len(machines), listen)))
+	if err := http.ListenAndServe(listen, nil); err != nil {
+		Exit(err.Error())
+	}
+}
+
+type metric struct {
+	name   string
+	help   string
+	typ    string
+	values []metricValue
+}
+
+type metricValue struct {
+	labels string
+	value  float64
+}
+
+// Writes the snapshots in the Prometheus text format.
+// The status rpc doesn't say whether a node is catching up, so we export how far
+// behind the highest node it is. Docker resets the restart counts when a container
+// is recreated, so they are gauges.
+func writeMetrics(w io.Writer, app string, snapshots []*nodeSnapshot) {
+	height := &metric{name: "mintnet_height", help: "Latest block height of the node", typ: "gauge"}
+	peers := &metric{name: "mintnet_peers", help: "Number of peers of the node", typ: "gauge"}
+	behind := &metric{name: "mintnet_blocks_behind", help: "Blocks the node is behind the highest node", typ: "gauge"}
+	rpcUp := &metric{name: "mintnet_rpc_up", help: "Whether the node answered status", typ: "gauge"}
+	containerUp := &metric{name: "mintnet_container_up", help: "Whether the container is running", typ: "gauge"}
+	restarts := &metric{name: "mintnet_container_restarts", help: "Times docker restarted the container since it was created", typ: "gauge"}
+
+	maxHeight := maxSnapshotHeight(snapshots)
+	for _, snap := range snapshots {
+		nodeLabels := Fmt(`app="%v",machine="%v"`, escapeLabel(app), escapeLabel(snap.Mach))
+		rpcUp.add(nodeLabels, boolValue(snap.RPCUp))
+		if snap.RPCUp {
+			height.add(nodeLabels, float64(snap.Height))
+			peers.add(nodeLabels, float64(snap.Peers))
+			behind.add(nodeLabels, float64(maxHeight-snap.Height))
+		}
+		components := []string{}
+		for component := range snap.Containers {
+			components = append(components, component)
+		}
+		sort.Strings(components)
+		for _, component := range components {
+			state := snap.Containers[component]
+			labels := Fmt(`%v,component="%v"`, nodeLabels, escapeLabel(component))
+			containerUp.add(labels, boolValue(state.Status == "running"))
+			restarts.add(labels, float64(state.Restarts))
+		}
+	}
+
+	var buf bytes.Buffer
+	for _, m := range []*metric{height, peers, behind, rpcUp, containerUp, restarts} {
+		fmt.Fprintf(&buf, "# HELP %v %v\n# TYPE %v %v\n", m.name, m.help, m.name, m.typ)
+		for _, v := range m.values {
+			fmt.Fprintf(&buf, "%v{%v} %v\n", m.name, v.labels, v.value)
+		}
+	}
+	w.Write(buf.Bytes())
+}
+
+func (m *metric) add(labels string, value float64) {
+	m.values = append(m.values, metricValue{labels, value})
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Escapes a label value for the Prometheus text format
+func escapeLabel(value string) string {
+	value = strings.Replace(value, `\`, `\\`, -1)
+	value = strings.Replace(value, `"`, `\"`, -1)
+	return strings.Replace(value, "\n", `\n`, -1)
+}
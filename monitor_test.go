@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	snapshots := []*nodeSnapshot{
+		{Mach: "mach1", RPCUp: true, Height: 10, Peers: 3, Containers: map[string]containerState{
+			"core": {Status: "running", Restarts: 2},
+			"app":  {Status: "exited"},
+		}},
+		{Mach: "mach2", RPCUp: true, Height: 8, Peers: 1},
+		{Mach: "mach3"},
+	}
+	var buf bytes.Buffer
+	writeMetrics(&buf, "mytest", snapshots)
+	out := buf.String()
+	for _, line := range []string{
+		"# TYPE mintnet_height gauge",
+		`mintnet_height{app="mytest",machine="mach1"} 10`,
+		`mintnet_blocks_behind{app="mytest",machine="mach1"} 0`,
+		`mintnet_blocks_behind{app="mytest",machine="mach2"} 2`,
+		"# TYPE mintnet_container_restarts gauge",
+		`mintnet_rpc_up{app="mytest",machine="mach3"} 0`,
+		`mintnet_container_up{app="mytest",machine="mach1",component="app"} 0`,
+		`mintnet_container_restarts{app="mytest",machine="mach1",component="core"} 2`,
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("Expected %v in\n%v", line, out)
+		}
+	}
+	if strings.Contains(out, `mintnet_height{app="mytest",machine="mach3"}`) {
+		t.Error("Expected no height for a node that is down")
+	}
+	if escaped := escapeLabel(`a"b\`); escaped != `a\"b\\` {
+		t.Errorf("Unexpected escaped label %v", escaped)
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"errors"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	. "github.com/tendermint/go-common"
+	"github.com/tendermint/go-wire"
+
+	"github.com/codegangsta/cli"
+)
+
+const NetworkFileName = "network.json"
+
+// What start learned about a running network, saved to baseDir/network.json
+// so later commands don't have to ask docker-machine again.
+type NetworkState struct {
+	App       string       `json:"app"`
+	ChainID   string       `json:"chain_id"`
+	StartTime time.Time    `json:"start_time"`
+	Nodes     []*NodeState `json:"nodes"`
+}
+
+// go-wire doesn't encode maps, so ports and containers are lists
+type NodeState struct {
+	Mach       string           `json:"mach"`
+	AppMachine string           `json:"app_machine,omitempty"`
+	Core       *CoreInfo        `json:"core"`
+	Ports      []*PortMapping   `json:"ports"` // of tmcore
+	Containers []*ContainerInfo `json:"containers"`
+}
+
+type PortMapping struct {
+	Port string `json:"port"` // in the container
+	Host string `json:"host"`
+}
+
+type ContainerInfo struct {
+	Name string `json:"name"`
+	ID   string `json:"id"`
+}
+
+func LoadNetworkState(file string) (*NetworkState, error) {
+	state := new(NetworkState)
+	if err := ReadJSONFile(state, file); err != nil {
+		return nil, errors.New(Fmt("Failed to read network state %v: %v", file, err))
+	}
+	return state, nil
+}
+
+func (s *NetworkState) Save(file string) error {
+	return WriteFile(file, wire.JSONBytesPretty(s), 0644)
+}
+
+func (s *NetworkState) Node(mach string) *NodeState {
+	for _, node := range s.Nodes {
+		if node.Mach == mach {
+			return node
+		}
+	}
+	return nil
+}
+
+// Adds the node, replacing any node on the same machine
+func (s *NetworkState) SetNode(node *NodeState) {
+	for i, old := range s.Nodes {
+		if old.Mach == node.Mach {
+			s.Nodes[i] = node
+			return
+		}
+	}
+	s.Nodes = append(s.Nodes, node)
+}
+
+func (s *NetworkState) RemoveNode(mach string) {
+	nodes := []*NodeState{}
+	for _, node := range s.Nodes {
+		if node.Mach != mach {
+			nodes = append(nodes, node)
+		}
+	}
+	s.Nodes = nodes
+}
+
+// Returns tmcore's container ports to host ports
+func (n *NodeState) PortMap() map[string]string {
+	portMap := make(map[string]string)
+	for _, mapping := range n.Ports {
+		portMap[mapping.Port] = mapping.Host
+	}
+	return portMap
+}
+
+func (s *NetworkState) Machines() []string {
+	machines := []string{}
+	for _, node := range s.Nodes {
+		machines = append(machines, node.Mach)
+	}
+	return machines
+}
+
+//--------------------------------------------------------------------------------
+
+// Collects the state of a started node: its core's ports and the ids of its containers
+func getNodeState(mach, app string, proj *Project, coreInfo *CoreInfo) (*NodeState, error) {
+	node := &NodeState{
+		Mach: mach,
+		Core: coreInfo,
+	}
+	if appMach := proj.AppMachine(mach); appMach != mach {
+		node.AppMachine = appMach
+	}
+	portMap, err := getContainerPortMap(mach, app+"_tmcore")
+	if err != nil {
+		return nil, err
+	}
+	ports := []string{}
+	for port := range portMap {
+		ports = append(ports, port)
+	}
+	sort.Strings(ports)
+	for _, port := range ports {
+		node.Ports = append(node.Ports, &PortMapping{port, portMap[port]})
+	}
+	machs := []string{mach}
+	if node.AppMachine != "" {
+		machs = append(machs, node.AppMachine)
+	}
+	for _, m := range machs {
+		ids, err := getContainerIDs(m, app)
+		if err != nil {
+			return nil, err
+		}
+		names := []string{}
+		for name := range ids {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			node.Containers = append(node.Containers, &ContainerInfo{name, ids[name]})
+		}
+	}
+	return node, nil
+}
+
+// Returns the ids of the app's containers on mach by name
+func getContainerIDs(mach, app string) (map[string]string, error) {
+	args := []string{"ssh", mach, Fmt(`docker ps -a --no-trunc --filter name=%v_tm --format "{{.Names}} {{.ID}}"`, app)}
+	output, ok := runProcessGetResult("container-ids-"+mach, "docker-machine", args, false)
+	if !ok {
+		return nil, errors.New("Failed to list containers on machine " + mach)
+	}
+	ids := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := strings.Fields(line)
+		// the filter matches substrings, so check the prefix
+		if len(fields) == 2 && strings.HasPrefix(fields[0], app+"_tm") {
+			ids[fields[0]] = fields[1]
+		}
+	}
+	return ids, nil
+}
+
+// Records nodes in baseDir/network.json.
+// A new network replaces the file, otherwise the nodes are added to it.
+func saveNetworkState(app, base string, nodes []*NodeState, newNetwork bool) error {
+	file := path.Join(base, NetworkFileName)
+	state := &NetworkState{App: app, StartTime: time.Now()}
+	if !newNetwork && FileExists(file) {
+		oldState, err := LoadNetworkState(file)
+		if err != nil {
+			return err
+		}
+		if oldState.App == app {
+			state = oldState
+		}
+	}
+	if state.ChainID == "" {
+		if initialized, err := initializedMachines(base); err == nil && len(initialized) > 0 {
+			if genDoc, _, err := readGenesis(base, initialized[0]); err == nil {
+				state.ChainID = genDoc.ChainID
+			}
+		}
+	}
+	for _, node := range nodes {
+		state.SetNode(node)
+	}
+	return state.Save(file)
+}
+
+// Drops the nodes that failed to start
+func compactNodeStates(nodeStates []*NodeState) []*NodeState {
+	nodes := []*NodeState{}
+	for _, node := range nodeStates {
+		if node != nil {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// Drops the nodes on machines from the network state file, if there is one
+func removeFromNetworkState(file string, machines []string) error {
+	if !FileExists(file) {
+		return nil
+	}
+	state, err := LoadNetworkState(file)
+	if err != nil {
+		return err
+	}
+	for _, mach := range machines {
+		state.RemoveNode(mach)
+	}
+	return state.Save(file)
+}
+
+// Returns mach's node in state, which may be nil
+func nodeFromState(state *NetworkState, mach string) *NodeState {
+	if state == nil {
+		return nil
+	}
+	return state.Node(mach)
+}
+
+// Returns the rpc address of mach's tmcore from the network state, or from docker-machine
+func getRPCAddr(state *NetworkState, mach, app string) (string, error) {
+	if node := nodeFromState(state, mach); node != nil && node.Core != nil {
+		return node.Core.RPCAddr, nil
+	}
+	_, rpcAddr, err := getCoreAddrs(mach, app)
+	return rpcAddr, err
+}
+
+// Loads the project of the network's baseDir: base if given, otherwise --base,
+// otherwise the directory of --network, where start saved network.json.
+// A --project file takes precedence, as for start
+func parseNetworkProject(c *cli.Context, base string) *Project {
+	if base == "" {
+		base = c.String("base")
+	}
+	if base == "" && c.String("network") != "" {
+		base = path.Dir(c.String("network"))
+	}
+	return ParseProject(c, base)
+}
+
+// Returns the network state from --network, or nil if not given
+func parseNetworkState(c *cli.Context) *NetworkState {
+	file := c.String("network")
+	if file == "" {
+		return nil
+	}
+	state, err := LoadNetworkState(file)
+	if err != nil {
+		Exit(err.Error())
+	}
+	return state
+}
+
+// Returns the network state from --network, if given, and the machines to work on:
+// --machines if set, otherwise the network's machines
+func parseNetworkMachines(c *cli.Context) ([]string, *NetworkState) {
+	state := parseNetworkState(c)
+	if state == nil {
+		return ParseMachines(c.String("machines")), nil
+	}
+	if c.IsSet("machines") {
+		return ParseMachines(c.String("machines")), state
+	}
+	return state.Machines(), state
+}
+
+// Returns the app name from the first arg, or from the network state
+func parseNetworkApp(args []string, state *NetworkState) (string, bool) {
+	if len(args) > 0 {
+		return args[0], true
+	}
+	if state != nil {
+		return state.App, true
+	}
+	return "", false
+}
+
+// Returns the machines whose steps all succeeded
+func succeededMachines(results []*machResult) []string {
+	machines := []string{}
+	for _, res := range results {
+		if !res.Failed() {
+			machines = append(machines, res.Mach)
+		}
+	}
+	return machines
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestNetworkStateSaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mintnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := path.Join(dir, NetworkFileName)
+
+	state := &NetworkState{App: "mytest", ChainID: "mychain", StartTime: time.Unix(1470000000, 0).UTC()}
+	state.SetNode(&NodeState{
+		Mach:       "mach1",
+		AppMachine: "mach2",
+		Core:       &CoreInfo{Validator: &Validator{ID: "mach1"}, P2PAddr: "10.0.0.1:32768", RPCAddr: "10.0.0.1:32769", Index: 1},
+		Ports:      []*PortMapping{{"46656", "32768"}, {"46657", "32769"}},
+		Containers: []*ContainerInfo{{"mytest_tmcore", "abc"}, {"mytest_tmcommon", "def"}},
+	})
+	if err := state.Save(file); err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	loaded, err := LoadNetworkState(file)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if loaded.App != "mytest" || loaded.ChainID != "mychain" || !loaded.StartTime.Equal(state.StartTime) {
+		t.Errorf("Unexpected network %v", loaded)
+	}
+	node := loaded.Node("mach1")
+	if node == nil || node.AppMachine != "mach2" || node.Core == nil || node.Core.RPCAddr != "10.0.0.1:32769" {
+		t.Fatalf("Unexpected node %v", node)
+	}
+	if node.Core.Validator == nil || node.Core.Validator.ID != "mach1" || node.Core.Index != 1 {
+		t.Errorf("Unexpected core %v", node.Core)
+	}
+	if portMap := node.PortMap(); portMap["46657"] != "32769" || len(portMap) != 2 {
+		t.Errorf("Unexpected ports %v", portMap)
+	}
+	if len(node.Containers) != 2 || node.Containers[1].Name != "mytest_tmcommon" || node.Containers[1].ID != "def" {
+		t.Errorf("Unexpected containers %v", node.Containers)
+	}
+}
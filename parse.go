@@ -18,20 +18,22 @@ func ParseMachines(machsStr string) []string {
 }
 
 // Takes a mach ranges like "foo[1,2,3];bar[3,4]baz"
-// and returns ["foo1", "foo2", "foo3", "bar3baz", "bar4baz"]
+// and returns ["foo1", "foo2", "foo3", "bar3baz", "bar4baz"].
+// Terms are separated by ";", or "," outside brackets and exclusions,
+// and may exclude machines after a "!", like "foo[1-5]!foo2,foo4;bar1"
 func parseMachines(machsStr string) ([]string, error) {
 	if len(machsStr) == 0 {
 		return nil, nil
 	}
-	machStrs := strings.Split(machsStr, ";")
+	machStrs := splitMachinesTerms(machsStr)
 	machsMap := map[string]struct{}{}
 	machs := []string{}
 	for _, machStr := range machStrs {
-		rangeMachs, err := parseMachinesRange(machStr)
+		termMachs, err := parseMachinesTerm(machStr)
 		if err != nil {
 			return nil, err
 		}
-		for _, mach := range rangeMachs {
+		for _, mach := range termMachs {
 			if _, ok := machsMap[mach]; ok {
 				return nil, errors.New(Fmt("Duplicate machine %v", mach))
 			}
@@ -42,56 +44,161 @@ func parseMachines(machsStr string) ([]string, error) {
 	return machs, nil
 }
 
-// Takes a mach range string like "foo[1,2,3]" and returns ["foo1", "foo2", "foo3"]
-func parseMachinesRange(machRange string) ([]string, error) {
-	re, err := regexp.Compile(`([0-9a-zA-Z_\-.]*)\[([0-9a-zA-Z_\-.,]+)\]([0-9a-zA-Z_\-.]*)`)
+// Splits on ";", and on "," outside brackets and exclusions,
+// so only a ";" ends the list of excluded machines
+func splitMachinesTerms(machsStr string) []string {
+	terms := []string{}
+	depth, start := 0, 0
+	excluding := false
+	for i, ch := range machsStr {
+		switch {
+		case ch == '[':
+			depth++
+		case ch == ']':
+			depth--
+		case ch == '!':
+			excluding = true
+		case ch == ';' || (ch == ',' && depth == 0 && !excluding):
+			terms = append(terms, machsStr[start:i])
+			start = i + 1
+			excluding = false
+		}
+	}
+	return append(terms, machsStr[start:])
+}
+
+// Takes a term like "foo[1-5]!foo[2,4]" or "foo[1-5]!foo2,foo4" and returns ["foo1", "foo3", "foo5"]
+func parseMachinesTerm(term string) ([]string, error) {
+	ranges := strings.Split(term, "!")
+	machines, err := parseMachinesRange(ranges[0])
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	machParts := re.FindStringSubmatch(machRange)
-	if len(machParts) == 0 {
-		return []string{machRange}, nil
+	if len(ranges) == 1 {
+		return machines, nil
 	}
-	if len(machParts) != 4 {
-		panic(Fmt("Expected 4 parts in parseMachinesRange, got %v", len(machParts)))
+	excluded := map[string]struct{}{}
+	for _, exclusion := range ranges[1:] {
+		for _, machRange := range splitMachinesTerms(exclusion) {
+			excludedMachs, err := parseMachinesRange(machRange)
+			if err != nil {
+				return nil, err
+			}
+			for _, mach := range excludedMachs {
+				excluded[mach] = struct{}{}
+			}
+		}
 	}
-	// fmt.Println(machParts[1], machParts[2], machParts[3])
-	rangeStrs, err := expressRange(machParts[2])
-	if err != nil {
-		return nil, err
+	included := []string{}
+	for _, mach := range machines {
+		if _, ok := excluded[mach]; !ok {
+			included = append(included, mach)
+		}
+	}
+	if len(included) == 0 {
+		return nil, errors.New(Fmt("Machines %v excludes every machine", term))
 	}
-	machines := []string{}
-	for _, rangeStr := range rangeStrs {
-		machines = append(machines, machParts[1]+rangeStr+machParts[3])
+	return included, nil
+}
+
+// Takes a mach range string like "dc[1-2]-node[1,2]"
+// and returns ["dc1-node1", "dc1-node2", "dc2-node1", "dc2-node2"]
+func parseMachinesRange(machRange string) ([]string, error) {
+	if machRange == "" {
+		return nil, errors.New("Empty machine name")
+	}
+	machines := []string{""}
+	rest := machRange
+	for rest != "" {
+		open, close := strings.Index(rest, "["), strings.Index(rest, "]")
+		literal := rest
+		if open != -1 {
+			literal = rest[:open]
+		}
+		if close != -1 && (open == -1 || close < open) {
+			return nil, errors.New(Fmt("Unmatched ] in machines %v", machRange))
+		}
+		if !machNameRE.MatchString(literal) {
+			return nil, errors.New(Fmt("Invalid machine name %v", machRange))
+		}
+		if open == -1 {
+			machines = appendEach(machines, []string{literal})
+			break
+		}
+		if close == -1 {
+			return nil, errors.New(Fmt("Unmatched [ in machines %v", machRange))
+		}
+		if strings.Contains(rest[open+1:close], "[") {
+			return nil, errors.New(Fmt("Nested [ in machines %v", machRange))
+		}
+		rangeStrs, err := expressRange(rest[open+1 : close])
+		if err != nil {
+			return nil, errors.New(Fmt("Invalid machines %v: %v", machRange, err))
+		}
+		machines = appendEach(machines, []string{literal})
+		machines = appendEach(machines, rangeStrs)
+		rest = rest[close+1:]
 	}
 	return machines, nil
 }
 
-// Takes a range string like "0,1,3-6" and returns ["0", "1", "3", "4", "5", "6"]
+var machNameRE = regexp.MustCompile(`^[0-9a-zA-Z_\-.]*$`)
+var rangeNumRE = regexp.MustCompile(`^[0-9]+$`)
+
+// Returns every prefix followed by every suffix
+func appendEach(prefixes, suffixes []string) []string {
+	joined := []string{}
+	for _, prefix := range prefixes {
+		for _, suffix := range suffixes {
+			joined = append(joined, prefix+suffix)
+		}
+	}
+	return joined
+}
+
+// Takes a range string like "0,1,3-6" and returns ["0", "1", "3", "4", "5", "6"].
+// A start with a leading zero pads to its width, so "08-10" is ["08", "09", "10"],
+// and a step follows a colon, so "0-20:5" is ["0", "5", "10", "15", "20"]
 func expressRange(rangeStr string) ([]string, error) {
 	rangeStrs := strings.Split(rangeStr, ",")
 	expressed := []string{}
 	for _, part := range rangeStrs {
-		if dashIdx := strings.Index(part, "-"); dashIdx == -1 {
-			expressed = append(expressed, part)
-		} else {
-			start := part[:dashIdx]
-			end := part[dashIdx+1:]
-			startNum, err := strconv.Atoi(start)
-			if err != nil {
-				return nil, err
-			}
-			endNum, err := strconv.Atoi(end)
-			if err != nil {
-				return nil, err
-			}
-			if startNum < 0 || startNum > endNum || startNum+1000 < endNum {
-				return nil, errors.New(Fmt("Invalid range %v-%v", startNum, endNum))
+		bounds, stepStr := part, ""
+		if colonIdx := strings.Index(part, ":"); colonIdx != -1 {
+			bounds, stepStr = part[:colonIdx], part[colonIdx+1:]
+		}
+		dashIdx := strings.Index(bounds, "-")
+		if dashIdx == -1 {
+			if part == "" || stepStr != "" || !machNameRE.MatchString(part) {
+				return nil, errors.New(Fmt("Invalid range item %q", part))
 			}
-			for i := startNum; i <= endNum; i++ {
-				expressed = append(expressed, Fmt("%v", i))
+			expressed = append(expressed, part)
+			continue
+		}
+		start := bounds[:dashIdx]
+		end := bounds[dashIdx+1:]
+		if !rangeNumRE.MatchString(start) || !rangeNumRE.MatchString(end) {
+			return nil, errors.New(Fmt("Invalid range %v", part))
+		}
+		startNum, _ := strconv.Atoi(start)
+		endNum, _ := strconv.Atoi(end)
+		step := 1
+		if stepStr != "" {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step < 1 {
+				return nil, errors.New(Fmt("Invalid step in range %v", part))
 			}
 		}
+		if startNum > endNum || (endNum-startNum)/step > 1000 {
+			return nil, errors.New(Fmt("Invalid range %v-%v", startNum, endNum))
+		}
+		width := 0
+		if len(start) > 1 && start[0] == '0' {
+			width = len(start)
+		}
+		for i := startNum; i <= endNum; i += step {
+			expressed = append(expressed, Fmt("%0*d", width, i))
+		}
 	}
 	return expressed, nil
 }
@@ -27,6 +27,48 @@ func TestParseRange(t *testing.T) {
 	expect(t, "[0-2];[4-6]", "0%1%2%4%5%6")
 }
 
+func TestParseRangeSyntax(t *testing.T) {
+	expect(t, "node[08-11]", "node08%node09%node10%node11")
+	expect(t, "node[001-002]", "node001%node002")
+	expect(t, "node[0-10]", "node0%node1%node2%node3%node4%node5%node6%node7%node8%node9%node10")
+	expect(t, "foo[0-20:5]", "foo0%foo5%foo10%foo15%foo20")
+	expect(t, "foo[1-6:2,10]", "foo1%foo3%foo5%foo10")
+	expect(t, "mach[1-6]!mach[3,5]", "mach1%mach2%mach4%mach6")
+	expect(t, "mach[1-4]!mach1!mach4;bar", "mach2%mach3%bar")
+	expect(t, "mach[1-5]!mach2,mach3", "mach1%mach4%mach5")
+	expect(t, "mach[1-5]!mach2,mach[4-5];bar[1,2]", "mach1%mach3%bar1%bar2")
+	expect(t, "dc[1-2]-node[1-2]", "dc1-node1%dc1-node2%dc2-node1%dc2-node2")
+	expect(t, "mach1,mach2;foo[1,2],bar", "mach1%mach2%foo1%foo2%bar")
+	expect(t, "dc[a,b].node[1,2].io", "dca.node1.io%dca.node2.io%dcb.node1.io%dcb.node2.io")
+}
+
+func TestParseRangeErrors(t *testing.T) {
+	for _, machsStr := range []string{
+		"foo[1-2",
+		"foo1-2]",
+		"foo][1",
+		"foo[[1-2]]",
+		"foo[]",
+		"foo[1,,2]",
+		"foo[2-1]",
+		"foo[a-2]",
+		"foo[1-5:0]",
+		"foo[1:2]",
+		"foo[0-2000]",
+		"foo bar",
+		"foo,",
+		"foo;",
+		"foo[1-2]!foo[1-2]",
+		"foo[1-2]!",
+		"foo[1-2]!foo1,",
+		"foo[1,1]",
+	} {
+		if machs, err := parseMachines(machsStr); err == nil {
+			t.Errorf("Expected error for %v, got %v", machsStr, machs)
+		}
+	}
+}
+
 func expect(t *testing.T, machsStr string, machsExpected string) {
 	machsGot, err := parseMachines(machsStr)
 	if err != nil {
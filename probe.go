@@ -0,0 +1,215 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	. "github.com/tendermint/go-common"
+)
+
+const defaultProbeTimeout = 45
+
+// A probe tells whether a service's container is ready.
+type Probe interface {
+	Check(mach, container string) error
+	String() string
+}
+
+// Selects a probe in the project file. Exactly one probe should be set, e.g.
+// {"unix": "/data/tendermint/app/app.sock", "timeout": 300}
+type ReadyCheck struct {
+	File    string `json:"file,omitempty"`    // path that must exist
+	Unix    string `json:"unix,omitempty"`    // unix socket someone is listening on
+	TCP     string `json:"tcp,omitempty"`     // [host:]port accepting connections
+	HTTP    string `json:"http,omitempty"`    // url returning 2xx
+	RPC     string `json:"rpc,omitempty"`     // container port of a tendermint rpc server answering status
+	Log     string `json:"log,omitempty"`     // regexp matching a line of the container's logs
+	Timeout int    `json:"timeout,omitempty"` // seconds, defaults to 45
+
+	// Image the unix, tcp and http probes run in, defaults to defaultProbeImage
+	Image string `json:"image,omitempty"`
+}
+
+// Returns the probe selected by the check
+func (check *ReadyCheck) Probe() (Probe, error) {
+	image := check.Image
+	if image == "" {
+		image = defaultProbeImage
+	}
+	probes := []Probe{}
+	if check.File != "" {
+		probes = append(probes, fileProbe{check.File})
+	}
+	if check.Unix != "" {
+		probes = append(probes, unixProbe{check.Unix, image})
+	}
+	if check.TCP != "" {
+		host, port := "127.0.0.1", check.TCP
+		if idx := strings.LastIndex(check.TCP, ":"); idx != -1 {
+			host, port = check.TCP[:idx], check.TCP[idx+1:]
+		}
+		probes = append(probes, tcpProbe{host, port, image})
+	}
+	if check.HTTP != "" {
+		probes = append(probes, httpProbe{check.HTTP, image})
+	}
+	if check.RPC != "" {
+		probes = append(probes, &rpcProbe{port: check.RPC})
+	}
+	if check.Log != "" {
+		re, err := regexp.Compile(check.Log)
+		if err != nil {
+			return nil, errors.New(Fmt("Invalid log probe %v: %v", check.Log, err))
+		}
+		probes = append(probes, logProbe{re})
+	}
+	if len(probes) != 1 {
+		return nil, errors.New(Fmt("Expected exactly one probe in ready check, got %v", len(probes)))
+	}
+	return probes[0], nil
+}
+
+// Polls the ready check until it passes or times out
+func waitReady(mach, container string, check *ReadyCheck) error {
+	if check == nil {
+		return nil
+	}
+	probe, err := check.Probe()
+	if err != nil {
+		return err
+	}
+	timeout := check.Timeout
+	if timeout == 0 {
+		timeout = defaultProbeTimeout
+	}
+	deadline := time.Now().Add(time.Duration(timeout) * time.Second)
+	for {
+		time.Sleep(time.Second * 2)
+		err := probe.Check(mach, container)
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return errors.New(Fmt("Failed to start %v on machine %v (timeout waiting for %v: %v)", container, mach, probe, err))
+		}
+		fmt.Println(Yellow(Fmt("%v on %v not ready (%v). Waiting...", container, mach, err)))
+	}
+}
+
+//--------------------------------------------------------------------------------
+
+// The unix, tcp and http probes run in an image with socat and curl, sharing the probed container's
+// network and volumes, since the services' own images may not have them.
+// Set "image" in the ready check to use another, e.g. one the machines have offline
+const defaultProbeImage = "nicolaka/netshoot:v0.13"
+
+// Runs a command in a probe container next to the container, failing with msg
+func helperProbe(label, mach, container, image string, cmd []string, msg string) error {
+	run := Fmt("docker run --rm --net=container:%v --volumes-from %v %v %v", container, container, image, shellJoin(cmd))
+	if _, ok := runProcessGetResult(label+"-"+mach, "docker-machine", []string{"ssh", mach, run}, false); !ok {
+		return errors.New(msg)
+	}
+	return nil
+}
+
+type fileProbe struct {
+	path string
+}
+
+func (p fileProbe) Check(mach, container string) error {
+	if !checkFileExists(mach, container, p.path) {
+		return errors.New(p.path + " does not exist")
+	}
+	return nil
+}
+
+func (p fileProbe) String() string { return "file " + p.path }
+
+// Connects to the socket, as a socket file left by an earlier container survives in the volume
+type unixProbe struct {
+	path  string
+	image string
+}
+
+func (p unixProbe) Check(mach, container string) error {
+	return helperProbe("probe-unix", mach, container, p.image, []string{"socat", "-u", "OPEN:/dev/null", "UNIX-CONNECT:" + p.path},
+		p.path+" is not accepting connections")
+}
+
+func (p unixProbe) String() string { return "unix socket " + p.path }
+
+type tcpProbe struct {
+	host  string
+	port  string
+	image string
+}
+
+func (p tcpProbe) Check(mach, container string) error {
+	return helperProbe("probe-tcp", mach, container, p.image, []string{"socat", "-u", "OPEN:/dev/null", Fmt("TCP:%v:%v", p.host, p.port)},
+		Fmt("%v:%v is not accepting connections", p.host, p.port))
+}
+
+func (p tcpProbe) String() string { return Fmt("tcp %v:%v", p.host, p.port) }
+
+type httpProbe struct {
+	url   string
+	image string
+}
+
+func (p httpProbe) Check(mach, container string) error {
+	return helperProbe("probe-http", mach, container, p.image, []string{"curl", "-sf", "-o", "/dev/null", p.url},
+		p.url+" did not return 2xx")
+}
+
+func (p httpProbe) String() string { return "http " + p.url }
+
+// Calls status on the rpc server through the port the container publishes
+type rpcProbe struct {
+	port    string
+	rpcAddr string // looked up once
+}
+
+func (p *rpcProbe) Check(mach, container string) error {
+	if p.rpcAddr == "" {
+		ip, err := getMachineIP(mach)
+		if err != nil {
+			return err
+		}
+		portMap, err := getContainerPortMap(mach, container)
+		if err != nil {
+			return err
+		}
+		rpcPort, ok := portMap[p.port]
+		if !ok {
+			return errors.New("No port map found for rpc port " + p.port)
+		}
+		p.rpcAddr = Fmt("%v:%v", ip, rpcPort)
+	}
+	_, err := getCoreStatus(p.rpcAddr)
+	return err
+}
+
+func (p *rpcProbe) String() string { return "rpc status on port " + p.port }
+
+type logProbe struct {
+	pattern *regexp.Regexp
+}
+
+func (p logProbe) Check(mach, container string) error {
+	args := []string{"ssh", mach, Fmt(`docker logs %v 2>&1`, container)}
+	output, ok := runProcessGetResult("probe-log-"+mach, "docker-machine", args, false)
+	if !ok {
+		return errors.New("Failed to get logs of " + container)
+	}
+	for _, line := range strings.Split(output, "\n") {
+		if p.pattern.MatchString(line) {
+			return nil
+		}
+	}
+	return errors.New("No log line matching " + p.pattern.String())
+}
+
+func (p logProbe) String() string { return "log line matching " + p.pattern.String() }
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestReadyCheckProbe(t *testing.T) {
+	cases := []struct {
+		check    ReadyCheck
+		expected string
+	}{
+		{ReadyCheck{File: "/data/tendermint/data/data.sock"}, "file /data/tendermint/data/data.sock"},
+		{ReadyCheck{Unix: "/data/tendermint/app/app.sock"}, "unix socket /data/tendermint/app/app.sock"},
+		{ReadyCheck{TCP: "46658"}, "tcp 127.0.0.1:46658"},
+		{ReadyCheck{TCP: "indexer:8080"}, "tcp indexer:8080"},
+		{ReadyCheck{RPC: "46657"}, "rpc status on port 46657"},
+		{ReadyCheck{Log: "Started node"}, "log line matching Started node"},
+	}
+	for _, c := range cases {
+		probe, err := c.check.Probe()
+		if err != nil {
+			t.Errorf("Unexpected error for %v: %v", c.check, err)
+			continue
+		}
+		if probe.String() != c.expected {
+			t.Errorf("Expected %v but got %v", c.expected, probe)
+		}
+	}
+
+	if probe, _ := (&ReadyCheck{Unix: "/app.sock"}).Probe(); probe.(unixProbe).image != defaultProbeImage {
+		t.Errorf("Expected the default probe image, got %v", probe.(unixProbe).image)
+	}
+	if probe, _ := (&ReadyCheck{TCP: "46658", Image: "local/socat"}).Probe(); probe.(tcpProbe).image != "local/socat" {
+		t.Errorf("Expected the probe's image, got %v", probe.(tcpProbe).image)
+	}
+
+	if _, err := (&ReadyCheck{}).Probe(); err == nil {
+		t.Error("Expected error for empty ready check")
+	}
+	if _, err := (&ReadyCheck{File: "a", TCP: "1"}).Probe(); err == nil {
+		t.Error("Expected error for two probes")
+	}
+	if _, err := (&ReadyCheck{Log: "("}).Probe(); err == nil {
+		t.Error("Expected error for invalid log regexp")
+	}
+}
+
+func TestProjectReadyCheck(t *testing.T) {
+	proj := &Project{
+		Machines: map[string]*MachineConfig{
+			"mach2": &MachineConfig{
+				Components: map[string]*ComponentConfig{
+					"core": &ComponentConfig{Ready: &ReadyCheck{Log: "Starting RPC"}},
+				},
+			},
+		},
+	}
+	if check := proj.ReadyCheck("mach1", "core"); check == nil || check.RPC != "46657" {
+		t.Errorf("Expected default rpc probe for core, got %v", check)
+	}
+	if check := proj.ReadyCheck("mach2", "core"); check == nil || check.Log != "Starting RPC" {
+		t.Errorf("Expected log probe for core on mach2, got %v", check)
+	}
+	if check := proj.ReadyCheck("mach1", "app"); check == nil || check.Unix == "" {
+		t.Errorf("Expected unix probe for app, got %v", check)
+	}
+	proj.ProxyApp = "tcp"
+	if check := proj.ReadyCheck("mach1", "app"); check == nil || check.TCP != "46658" {
+		t.Errorf("Expected tcp probe for app, got %v", check)
+	}
+}
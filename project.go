@@ -0,0 +1,332 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+
+	. "github.com/tendermint/go-common"
+
+	"github.com/codegangsta/cli"
+)
+
+const (
+	DefaultImage    = "tendermint/tmbase"
+	ProjectFileName = "mintnet.json"
+)
+
+// Project-wide configuration, read from <baseDir>/mintnet.json.
+// Components are keyed by "common" or a service name, like "data", "app" and "core".
+// Settings under "machines" override the global ones for that machine,
+// except lists like ulimits and volumes, which are added to.
+type Project struct {
+	// How tmcore connects to the app: unix, tcp, or grpc
+	ProxyApp string `json:"proxy_app,omitempty"`
+
+	// Services run by every node, in addition to data, app and core
+	Services []Service `json:"services,omitempty"`
+
+	Components map[string]*ComponentConfig `json:"components,omitempty"`
+	Machines   map[string]*MachineConfig   `json:"machines,omitempty"`
+
+	// Named docker-machine templates, and which machines to create from them
+	Templates map[string]*MachineTemplate `json:"templates,omitempty"`
+	Create    []*CreateGroup              `json:"create,omitempty"`
+}
+
+type MachineConfig struct {
+	// Run this machine's data and app containers on another machine.
+	// Requires proxy_app tcp or grpc.
+	AppMachine string `json:"app_machine,omitempty"`
+
+	// Services run by this node only
+	Services []Service `json:"services,omitempty"`
+
+	Components map[string]*ComponentConfig `json:"components,omitempty"`
+}
+
+// How to create a machine with docker-machine.
+// Region, size, image and tags are translated to the driver's flags.
+type MachineTemplate struct {
+	Driver string   `json:"driver"`
+	Region string   `json:"region,omitempty"`
+	Size   string   `json:"size,omitempty"`
+	Image  string   `json:"image,omitempty"`
+	Tags   []string `json:"tags,omitempty"`
+
+	// Extra docker-machine create args, like access tokens. $VARS are expanded
+	Args []string `json:"args,omitempty"`
+}
+
+// Machines to create from a template, e.g. {"machines": "us[1-3]", "template": "do-nyc"}
+type CreateGroup struct {
+	Machines string `json:"machines"`
+	Template string `json:"template"`
+}
+
+type ComponentConfig struct {
+	Image string `json:"image,omitempty"`
+
+	// Prebuilt images already contain the software, and their entrypoint
+	// starts it. We run them without init.sh so nothing is compiled at start.
+	Prebuilt bool `json:"prebuilt,omitempty"`
+
+	// How to tell the container is ready. Nil means ready once started
+	Ready *ReadyCheck `json:"ready,omitempty"`
+
+	// Options passed through to docker run
+	CPUShares  int               `json:"cpu_shares,omitempty"`
+	CPUSet     string            `json:"cpuset_cpus,omitempty"`
+	Memory     string            `json:"memory,omitempty"`
+	Restart    string            `json:"restart,omitempty"`
+	Ulimits    []string          `json:"ulimits,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Env        map[string]string `json:"env,omitempty"`
+	Volumes    []string          `json:"volumes,omitempty"`
+	DockerArgs []string          `json:"docker_args,omitempty"`
+}
+
+func (cfg *ComponentConfig) merge(other *ComponentConfig) {
+	if other == nil {
+		return
+	}
+	// An image and whether it's prebuilt go together
+	if other.Image != "" {
+		cfg.Image = other.Image
+		cfg.Prebuilt = other.Prebuilt
+	}
+	if other.Ready != nil {
+		cfg.Ready = other.Ready
+	}
+	if other.CPUShares != 0 {
+		cfg.CPUShares = other.CPUShares
+	}
+	if other.CPUSet != "" {
+		cfg.CPUSet = other.CPUSet
+	}
+	if other.Memory != "" {
+		cfg.Memory = other.Memory
+	}
+	if other.Restart != "" {
+		cfg.Restart = other.Restart
+	}
+	// Lists accumulate, maps are merged key by key
+	cfg.Ulimits = append(cfg.Ulimits, other.Ulimits...)
+	cfg.Volumes = append(cfg.Volumes, other.Volumes...)
+	cfg.DockerArgs = append(cfg.DockerArgs, other.DockerArgs...)
+	cfg.Labels = mergeStringMaps(cfg.Labels, other.Labels)
+	cfg.Env = mergeStringMaps(cfg.Env, other.Env)
+}
+
+func mergeStringMaps(dst, src map[string]string) map[string]string {
+	if len(src) == 0 {
+		return dst
+	}
+	if dst == nil {
+		dst = make(map[string]string)
+	}
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
+
+// Returns the extra docker run options for the component,
+// each followed by a space so it can be spliced into a command.
+func (cfg *ComponentConfig) RunOpts() string {
+	opts := []string{}
+	if cfg.CPUShares != 0 {
+		opts = append(opts, Fmt("--cpu-shares=%v", cfg.CPUShares))
+	}
+	if cfg.CPUSet != "" {
+		opts = append(opts, "--cpuset-cpus="+shellQuote(cfg.CPUSet))
+	}
+	if cfg.Memory != "" {
+		opts = append(opts, "--memory="+shellQuote(cfg.Memory))
+	}
+	if cfg.Restart != "" {
+		opts = append(opts, "--restart="+shellQuote(cfg.Restart))
+	}
+	for _, ulimit := range cfg.Ulimits {
+		opts = append(opts, "--ulimit="+shellQuote(ulimit))
+	}
+	for _, k := range sortedKeys(cfg.Labels) {
+		opts = append(opts, "--label="+shellQuote(k+"="+cfg.Labels[k]))
+	}
+	for _, k := range sortedKeys(cfg.Env) {
+		opts = append(opts, "-e "+shellQuote(k+"="+cfg.Env[k]))
+	}
+	for _, volume := range cfg.Volumes {
+		opts = append(opts, "-v "+shellQuote(volume))
+	}
+	opts = append(opts, cfg.DockerArgs...)
+	if len(opts) == 0 {
+		return ""
+	}
+	return strings.Join(opts, " ") + " "
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Returns the configuration for component on mach.
+// The service definition is applied first, then the global configuration, then the machine's.
+func (p *Project) Component(mach, name string) *ComponentConfig {
+	cfg := &ComponentConfig{Image: DefaultImage}
+	if p == nil {
+		return cfg
+	}
+	if services, err := p.NodeServices(mach); err == nil {
+		if svc := findService(services, name); svc != nil {
+			cfg.merge(&svc.ComponentConfig)
+		}
+	}
+	cfg.merge(p.Components[name])
+	if machCfg, ok := p.Machines[mach]; ok {
+		cfg.merge(machCfg.Components[name])
+	}
+	return cfg
+}
+
+// Sets the component's global image, keeping the rest of its config.
+// As in merge, the image brings its prebuilt flag, here false
+func (p *Project) SetImage(component, image string) {
+	if p.Components == nil {
+		p.Components = make(map[string]*ComponentConfig)
+	}
+	if cfg, ok := p.Components[component]; ok {
+		cfg.Image = image
+		cfg.Prebuilt = false
+		return
+	}
+	p.Components[component] = &ComponentConfig{Image: image}
+}
+
+// Returns the machine that runs the data and app containers for mach
+func (p *Project) AppMachine(mach string) string {
+	if p == nil {
+		return mach
+	}
+	if machCfg, ok := p.Machines[mach]; ok && machCfg.AppMachine != "" {
+		return machCfg.AppMachine
+	}
+	return mach
+}
+
+// Returns true if mach runs the app for a tmcore on some other machine
+func (p *Project) IsRemoteAppMachine(mach string) bool {
+	if p == nil {
+		return false
+	}
+	for coreMach, machCfg := range p.Machines {
+		if machCfg.AppMachine == mach && coreMach != mach {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks that the app machines for the given core machines can be used.
+// Each remote app machine serves a single core, since container names are per app.
+func (p *Project) ValidateAppMachines(machines []string) error {
+	switch p.ProxyApp {
+	case "", "unix", "tcp", "grpc":
+	default:
+		return errors.New(Fmt("Unknown proxy app %v. Expected unix, tcp, or grpc", p.ProxyApp))
+	}
+	isCore := make(map[string]bool)
+	for _, mach := range machines {
+		isCore[mach] = true
+	}
+	usedBy := make(map[string]string)
+	for _, mach := range machines {
+		appMach := p.AppMachine(mach)
+		if appMach == mach {
+			continue
+		}
+		if p.ProxyApp == "" || p.ProxyApp == "unix" {
+			return errors.New(Fmt("Machine %v runs its app on %v, which requires proxy app tcp or grpc", mach, appMach))
+		}
+		if isCore[appMach] {
+			return errors.New(Fmt("App machine %v for %v is already running a core", appMach, mach))
+		}
+		if other, ok := usedBy[appMach]; ok {
+			return errors.New(Fmt("App machine %v is used by both %v and %v", appMach, other, mach))
+		}
+		usedBy[appMach] = mach
+	}
+	return nil
+}
+
+// Returns the machines of every create group, and the template for each machine
+func (p *Project) CreateMachines() ([]string, map[string]*MachineTemplate, error) {
+	machines := []string{}
+	templates := make(map[string]*MachineTemplate)
+	for _, group := range p.Create {
+		tmpl, ok := p.Templates[group.Template]
+		if !ok {
+			return nil, nil, errors.New(Fmt("Unknown template %v for machines %v", group.Template, group.Machines))
+		}
+		groupMachs, err := parseMachines(group.Machines)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, mach := range groupMachs {
+			if _, ok := templates[mach]; ok {
+				return nil, nil, errors.New(Fmt("Machine %v is in more than one create group", mach))
+			}
+			templates[mach] = tmpl
+			machines = append(machines, mach)
+		}
+	}
+	return machines, templates, nil
+}
+
+// Loads a project file. A missing file is an empty project.
+func LoadProject(file string) (*Project, error) {
+	proj := &Project{}
+	if file == "" || !FileExists(file) {
+		return proj, nil
+	}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, proj); err != nil {
+		return nil, errors.New(Fmt("Error reading project file %v: %v", file, err))
+	}
+	return proj, nil
+}
+
+// Loads the project for base, honoring the --project, --image and --proxy-app flags.
+// Commands without a baseDir only read a project file given with --project.
+func ParseProject(c *cli.Context, base string) *Project {
+	file := c.String("project")
+	if file == "" && base != "" {
+		file = path.Join(base, ProjectFileName)
+	}
+	proj, err := LoadProject(file)
+	if err != nil {
+		Exit(err.Error())
+	}
+	for _, imageStr := range c.StringSlice("image") {
+		spl := strings.SplitN(imageStr, "=", 2)
+		if len(spl) != 2 || spl[0] == "" || spl[1] == "" {
+			Exit(Fmt("Invalid image %v. Expected component=image", imageStr))
+		}
+		proj.SetImage(spl[0], spl[1])
+	}
+	if proxyApp := c.String("proxy-app"); proxyApp != "" {
+		proj.ProxyApp = proxyApp
+	}
+	return proj
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestProjectComponent(t *testing.T) {
+	proj := &Project{
+		Components: map[string]*ComponentConfig{
+			"core": &ComponentConfig{Image: "tendermint/tendermint", Prebuilt: true},
+		},
+		Machines: map[string]*MachineConfig{
+			"mach2": &MachineConfig{
+				Components: map[string]*ComponentConfig{
+					"core": &ComponentConfig{Image: "tendermint/tmbase:develop"},
+				},
+			},
+		},
+	}
+
+	if cfg := proj.Component("mach1", "app"); cfg.Image != DefaultImage || cfg.Prebuilt {
+		t.Errorf("Expected default app image, got %v", cfg)
+	}
+	if cfg := proj.Component("mach1", "core"); cfg.Image != "tendermint/tendermint" || !cfg.Prebuilt {
+		t.Errorf("Expected prebuilt core image, got %v", cfg)
+	}
+	if cfg := proj.Component("mach2", "core"); cfg.Image != "tendermint/tmbase:develop" || cfg.Prebuilt {
+		t.Errorf("Expected machine override for core image, got %v", cfg)
+	}
+
+	var nilProj *Project
+	if cfg := nilProj.Component("mach1", "core"); cfg.Image != DefaultImage {
+		t.Errorf("Expected default image for nil project, got %v", cfg)
+	}
+}
+
+func TestProjectSetImage(t *testing.T) {
+	proj := &Project{
+		Components: map[string]*ComponentConfig{
+			"core": &ComponentConfig{Prebuilt: true, Ready: &ReadyCheck{Log: "Starting RPC"}, Memory: "1g"},
+		},
+	}
+	proj.SetImage("core", "tendermint/tendermint:develop")
+	proj.SetImage("app", "myapp")
+	cfg := proj.Component("mach1", "core")
+	if cfg.Image != "tendermint/tendermint:develop" || cfg.Ready == nil || cfg.Memory != "1g" {
+		t.Errorf("Expected the image override to keep the core config, got %v", cfg)
+	}
+	if cfg.Prebuilt {
+		t.Error("Expected the image override to not be prebuilt")
+	}
+	if cfg := proj.Component("mach1", "app"); cfg.Image != "myapp" {
+		t.Errorf("Expected app image myapp, got %v", cfg.Image)
+	}
+}
+
+func TestProjectRunOpts(t *testing.T) {
+	proj := &Project{
+		Components: map[string]*ComponentConfig{
+			"core": &ComponentConfig{
+				Memory:  "512m",
+				Ulimits: []string{"nofile=1024:2048"},
+				Env:     map[string]string{"LOG": "info"},
+			},
+		},
+		Machines: map[string]*MachineConfig{
+			"mach1": &MachineConfig{
+				Components: map[string]*ComponentConfig{
+					"core": &ComponentConfig{
+						Memory:  "1g",
+						Restart: "on-failure",
+						Env:     map[string]string{"DEBUG": "1"},
+					},
+				},
+			},
+		},
+	}
+
+	opts := proj.Component("mach1", "core").RunOpts()
+	expected := `--memory='1g' --restart='on-failure' --ulimit='nofile=1024:2048' -e 'DEBUG=1' -e 'LOG=info' `
+	if opts != expected {
+		t.Errorf("Expected %v but got %v", expected, opts)
+	}
+	// Values reach the remote shell as they are
+	cfg := &ComponentConfig{Env: map[string]string{"PASSWORD": "a$b`c`!'d"}}
+	if opts := cfg.RunOpts(); opts != `-e 'PASSWORD=a$b`+"`c`"+`!'\''d' ` {
+		t.Errorf("Unexpected quoting %v", opts)
+	}
+	if opts := proj.Component("mach1", "app").RunOpts(); opts != "" {
+		t.Errorf("Expected no options for app, got %v", opts)
+	}
+}
+
+func TestProjectAppMachines(t *testing.T) {
+	proj := &Project{
+		ProxyApp: "tcp",
+		Machines: map[string]*MachineConfig{
+			"mach1": &MachineConfig{AppMachine: "app1"},
+		},
+	}
+	if appMach := proj.AppMachine("mach1"); appMach != "app1" {
+		t.Errorf("Expected app1, got %v", appMach)
+	}
+	if appMach := proj.AppMachine("mach2"); appMach != "mach2" {
+		t.Errorf("Expected mach2, got %v", appMach)
+	}
+	if !proj.IsRemoteAppMachine("app1") || proj.IsRemoteAppMachine("mach1") {
+		t.Error("Expected only app1 to be a remote app machine")
+	}
+	if err := proj.ValidateAppMachines([]string{"mach1", "mach2"}); err != nil {
+		t.Error("Unexpected error:", err)
+	}
+	if err := proj.ValidateAppMachines([]string{"mach1", "app1"}); err == nil {
+		t.Error("Expected error for app machine running a core")
+	}
+
+	proj.ProxyApp = "unix"
+	if err := proj.ValidateAppMachines([]string{"mach1"}); err == nil {
+		t.Error("Expected error for remote app over a unix socket")
+	}
+}
+
+func TestProjectCreateMachines(t *testing.T) {
+	proj := &Project{
+		Templates: map[string]*MachineTemplate{
+			"do": &MachineTemplate{Driver: "digitalocean", Region: "nyc3", Size: "2gb", Tags: []string{"a", "b"}},
+			"aws": &MachineTemplate{Driver: "amazonec2", Region: "eu-west-1", Tags: []string{"net=test", "b"},
+				Args: []string{"--amazonec2-zone=b"}},
+		},
+		Create: []*CreateGroup{
+			&CreateGroup{Machines: "us[1-2]", Template: "do"},
+			&CreateGroup{Machines: "eu[1]", Template: "aws"},
+		},
+	}
+	machines, templates, err := proj.CreateMachines()
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if strings.Join(machines, ",") != "us1,us2,eu1" {
+		t.Errorf("Unexpected machines %v", machines)
+	}
+
+	args, err := templates["us2"].CreateArgs()
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	expected := "--driver=digitalocean --digitalocean-region=nyc3 --digitalocean-size=2gb --digitalocean-tags=a,b"
+	if strings.Join(args, " ") != expected {
+		t.Errorf("Expected %v but got %v", expected, strings.Join(args, " "))
+	}
+	args, err = templates["eu1"].CreateArgs()
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	expected = "--driver=amazonec2 --amazonec2-region=eu-west-1 --amazonec2-tags=net,test,b, --amazonec2-zone=b"
+	if strings.Join(args, " ") != expected {
+		t.Errorf("Expected %v but got %v", expected, strings.Join(args, " "))
+	}
+
+	if _, err := (&MachineTemplate{Driver: "virtualbox", Size: "big"}).CreateArgs(); err == nil {
+		t.Error("Expected error for size with an unmapped driver")
+	}
+
+	proj.Create = append(proj.Create, &CreateGroup{Machines: "us2", Template: "aws"})
+	if _, _, err := proj.CreateMachines(); err == nil {
+		t.Error("Expected error for a machine in two groups")
+	}
+	proj.Create = []*CreateGroup{&CreateGroup{Machines: "x1", Template: "none"}}
+	if _, _, err := proj.CreateMachines(); err == nil {
+		t.Error("Expected error for an unknown template")
+	}
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"time"
+
+	. "github.com/tendermint/go-common"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+
+	"github.com/codegangsta/cli"
+)
+
+// What the report needs from a block and the commit for it
+type blockSample struct {
+	Height int
+	Time   time.Time
+	NumTxs int
+	Round  int    // round the block was committed in, -1 if unknown
+	Signed []bool // by validator index, nil if the commit wasn't read
+
+	ValidatorsHash string // of the set that signed the commit
+}
+
+type chainReport struct {
+	FromHeight int                `json:"from_height"`
+	ToHeight   int                `json:"to_height"`
+	Blocks     int                `json:"blocks"`
+	Interval   intervalStats      `json:"block_interval"`
+	Txs        txStats            `json:"txs"`
+	Rounds     map[int]int        `json:"rounds"` // number of heights by the round they were committed in
+	Validators []*validatorReport `json:"validators"`
+
+	// Commits signed by another validator set than the current one, which the validators don't count
+	OtherSetHeights []int `json:"other_set_heights,omitempty"`
+}
+
+type intervalStats struct {
+	Min string `json:"min"`
+	Avg string `json:"avg"`
+	P50 string `json:"p50"`
+	P90 string `json:"p90"`
+	Max string `json:"max"`
+}
+
+type txStats struct {
+	Total       int     `json:"total"`
+	PerBlockAvg float64 `json:"per_block_avg"`
+	PerBlockMax int     `json:"per_block_max"`
+}
+
+type validatorReport struct {
+	ID            string  `json:"id"`
+	Signed        int     `json:"signed"`
+	Missed        int     `json:"missed"`
+	Participation float64 `json:"participation"` // share of commits signed
+	MissedHeights []int   `json:"missed_heights,omitempty"`
+}
+
+//--------------------------------------------------------------------------------
+
+// Report block production and consensus participation over a range of heights.
+// Blocks are read round-robin from the nodes, moving on to the next node when one fails.
+func cmdReport(c *cli.Context) {
+	args := c.Args()
+	machines, state := parseNetworkMachines(c)
+	app, ok := parseNetworkApp(args, state)
+	if !ok {
+		cli.ShowAppHelp(c)
+		return
+	}
+	format := c.String("format")
+	if format != "markdown" && format != "json" {
+		Exit("--format must be markdown or json")
+	}
+	rpcAddrs := []string{}
+	for _, mach := range machines {
+		rpcAddr, err := getRPCAddr(state, mach, app)
+		if err != nil {
+			fmt.Println(Yellow(Fmt("Skipping %v: %v", mach, err)))
+			continue
+		}
+		rpcAddrs = append(rpcAddrs, rpcAddr)
+	}
+	if len(rpcAddrs) == 0 {
+		Exit("No node to read blocks from")
+	}
+	reader := &blockReader{rpcAddrs: rpcAddrs}
+
+	latest, err := reader.latestHeight()
+	if err != nil {
+		Exit(err.Error())
+	}
+	from, to := c.Int("from-height"), c.Int("to-height")
+	if to == 0 || to > latest {
+		to = latest
+	}
+	if from < 1 || from > to {
+		Exit(Fmt("Invalid height range %v to %v, the latest height is %v", from, to, latest))
+	}
+
+	ids, err := validatorIDs(reader, c.String("validator-set"))
+	if err != nil {
+		Exit(err.Error())
+	}
+	// The rpc only has the current validators, so commits by other sets can't be attributed
+	latestBlock, err := reader.block(latest)
+	if err != nil {
+		Exit(err.Error())
+	}
+	currentSet := Fmt("%X", latestBlock.Block.Header.ValidatorsHash)
+
+	// The commit for a block is in the next one
+	samples := []*blockSample{}
+	for height := from; height <= to; height++ {
+		sample, err := reader.sample(height, height < latest)
+		if err != nil {
+			Exit(err.Error())
+		}
+		samples = append(samples, sample)
+	}
+	var prevTime time.Time
+	if from > 1 {
+		if prev, err := reader.block(from - 1); err == nil {
+			prevTime = prev.Block.Header.Time
+		}
+	}
+	report := buildReport(samples, prevTime, ids, currentSet)
+
+	var out []byte
+	if format == "json" {
+		out, _ = json.MarshalIndent(report, "", "  ")
+		out = append(out, '\n')
+	} else {
+		out = report.Markdown()
+	}
+	if file := c.String("out"); file != "" {
+		if err := WriteFile(file, out, 0644); err != nil {
+			Exit(err.Error())
+		}
+		fmt.Println(Green("Wrote report to " + file))
+		return
+	}
+	fmt.Print(string(out))
+}
+
+// Names the validators by index in the current set: the ids in validator_set.json, or their addresses
+func validatorIDs(reader *blockReader, valSetDir string) ([]string, error) {
+	var result *ctypes.ResultValidators
+	err := reader.try(func(rpcAddr string) (err error) {
+		result, err = getValidators(rpcAddr)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string)
+	if valSetDir != "" {
+		var valSet ValidatorSet
+		if err := ReadJSONFile(&valSet, path.Join(valSetDir, "validator_set.json")); err != nil {
+			return nil, err
+		}
+		for _, val := range valSet.Validators {
+			names[val.PubKey.KeyString()] = val.ID
+		}
+	}
+	ids := []string{}
+	for _, val := range result.Validators {
+		id, ok := names[val.PubKey.KeyString()]
+		if !ok {
+			id = Fmt("%X", val.Address)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// Reads blocks from the first node that answers, starting with the next one each time.
+// It keeps the last block read, as each sample's next block is the following sample's block.
+type blockReader struct {
+	rpcAddrs []string
+	next     int
+	last     *ctypes.ResultBlock
+}
+
+func (r *blockReader) try(fn func(rpcAddr string) error) error {
+	var err error
+	for i := 0; i < len(r.rpcAddrs); i++ {
+		rpcAddr := r.rpcAddrs[r.next%len(r.rpcAddrs)]
+		r.next++
+		if err = fn(rpcAddr); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func (r *blockReader) latestHeight() (int, error) {
+	height := 0
+	err := r.try(func(rpcAddr string) error {
+		status, err := getCoreStatus(rpcAddr)
+		if err == nil {
+			height = status.LatestBlockHeight
+		}
+		return err
+	})
+	return height, err
+}
+
+func (r *blockReader) block(height int) (*ctypes.ResultBlock, error) {
+	if r.last != nil && r.last.Block.Header.Height == height {
+		return r.last, nil
+	}
+	var block *ctypes.ResultBlock
+	err := r.try(func(rpcAddr string) (err error) {
+		block, err = getBlock(rpcAddr, height)
+		return err
+	})
+	if err != nil {
+		return nil, errors.New(Fmt("Failed to get block %v: %v", height, err))
+	}
+	r.last = block
+	return block, nil
+}
+
+// Reads the block at height, and if withCommit, the next block for its commit
+func (r *blockReader) sample(height int, withCommit bool) (*blockSample, error) {
+	result, err := r.block(height)
+	if err != nil {
+		return nil, err
+	}
+	sample := &blockSample{
+		Height: height,
+		Time:   result.Block.Header.Time,
+		NumTxs: len(result.Block.Data.Txs),
+		Round:  -1,
+
+		ValidatorsHash: Fmt("%X", result.Block.Header.ValidatorsHash),
+	}
+	if !withCommit {
+		return sample, nil
+	}
+	next, err := r.block(height + 1)
+	if err != nil {
+		return nil, err
+	}
+	commit := next.Block.LastCommit
+	sample.Round = commit.Round()
+	sample.Signed = make([]bool, len(commit.Precommits))
+	for i, vote := range commit.Precommits {
+		sample.Signed[i] = vote != nil
+	}
+	return sample, nil
+}
+
+//--------------------------------------------------------------------------------
+
+// Summarizes the samples. prevTime is the time of the block before the first, if known.
+// ids name the validators of the set with hash currentSet, whose commits alone are counted
+func buildReport(samples []*blockSample, prevTime time.Time, ids []string, currentSet string) *chainReport {
+	report := &chainReport{Blocks: len(samples), Rounds: make(map[int]int)}
+	if len(samples) == 0 {
+		return report
+	}
+	report.FromHeight = samples[0].Height
+	report.ToHeight = samples[len(samples)-1].Height
+
+	intervals := []time.Duration{}
+	total := time.Duration(0)
+	for _, sample := range samples {
+		if !prevTime.IsZero() {
+			interval := sample.Time.Sub(prevTime)
+			intervals = append(intervals, interval)
+			total += interval
+		}
+		prevTime = sample.Time
+
+		report.Txs.Total += sample.NumTxs
+		if sample.NumTxs > report.Txs.PerBlockMax {
+			report.Txs.PerBlockMax = sample.NumTxs
+		}
+		if sample.Round >= 0 {
+			report.Rounds[sample.Round]++
+		}
+		if sample.Signed != nil && sample.ValidatorsHash != currentSet {
+			report.OtherSetHeights = append(report.OtherSetHeights, sample.Height)
+		}
+	}
+	report.Txs.PerBlockAvg = float64(report.Txs.Total) / float64(len(samples))
+	if len(intervals) > 0 {
+		report.Interval = intervalStats{
+			Min: percentile(intervals, 0).String(),
+			Avg: (total / time.Duration(len(intervals))).String(),
+			P50: percentile(intervals, 50).String(),
+			P90: percentile(intervals, 90).String(),
+			Max: percentile(intervals, 100).String(),
+		}
+	}
+
+	for i, id := range ids {
+		val := &validatorReport{ID: id}
+		for _, sample := range samples {
+			if sample.Signed == nil || sample.ValidatorsHash != currentSet {
+				continue
+			}
+			if i < len(sample.Signed) && sample.Signed[i] {
+				val.Signed++
+			} else {
+				val.Missed++
+				val.MissedHeights = append(val.MissedHeights, sample.Height)
+			}
+		}
+		if commits := val.Signed + val.Missed; commits > 0 {
+			val.Participation = float64(val.Signed) / float64(commits)
+		}
+		report.Validators = append(report.Validators, val)
+	}
+	return report
+}
+
+func (report *chainReport) Markdown() []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Chain report for heights %v to %v\n\n", report.FromHeight, report.ToHeight)
+
+	fmt.Fprintf(&buf, "## Blocks\n\n")
+	fmt.Fprintf(&buf, "| Blocks | Min interval | Avg | P50 | P90 | Max |\n|---|---|---|---|---|---|\n")
+	iv := report.Interval
+	fmt.Fprintf(&buf, "| %v | %v | %v | %v | %v | %v |\n\n", report.Blocks, iv.Min, iv.Avg, iv.P50, iv.P90, iv.Max)
+
+	fmt.Fprintf(&buf, "## Txs\n\n")
+	fmt.Fprintf(&buf, "| Total | Avg per block | Max per block |\n|---|---|---|\n")
+	fmt.Fprintf(&buf, "| %v | %.2f | %v |\n\n", report.Txs.Total, report.Txs.PerBlockAvg, report.Txs.PerBlockMax)
+
+	fmt.Fprintf(&buf, "## Rounds\n\n")
+	fmt.Fprintf(&buf, "| Round | Heights |\n|---|---|\n")
+	rounds := []int{}
+	for round := range report.Rounds {
+		rounds = append(rounds, round)
+	}
+	sort.Ints(rounds)
+	for _, round := range rounds {
+		fmt.Fprintf(&buf, "| %v | %v |\n", round, report.Rounds[round])
+	}
+
+	fmt.Fprintf(&buf, "\n## Validators\n\n")
+	if n := len(report.OtherSetHeights); n > 0 {
+		fmt.Fprintf(&buf, "%v commits between heights %v and %v were signed by another validator set and are not counted.\n\n",
+			n, report.OtherSetHeights[0], report.OtherSetHeights[n-1])
+	}
+	fmt.Fprintf(&buf, "| Validator | Signed | Missed | Participation |\n|---|---|---|---|\n")
+	for _, val := range report.Validators {
+		fmt.Fprintf(&buf, "| %v | %v | %v | %.1f%% |\n", val.ID, val.Signed, val.Missed, val.Participation*100)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildReport(t *testing.T) {
+	start := time.Now()
+	samples := []*blockSample{
+		{Height: 2, Time: start.Add(time.Second), NumTxs: 4, Round: 0, Signed: []bool{true, true, true}},
+		{Height: 3, Time: start.Add(2 * time.Second), NumTxs: 0, Round: 1, Signed: []bool{true, false, true}},
+		{Height: 4, Time: start.Add(5 * time.Second), NumTxs: 2, Round: -1},
+	}
+	report := buildReport(samples, start, []string{"val0", "val1", "val2"}, "")
+
+	if report.FromHeight != 2 || report.ToHeight != 4 || report.Blocks != 3 {
+		t.Errorf("Unexpected range %v to %v with %v blocks", report.FromHeight, report.ToHeight, report.Blocks)
+	}
+	if report.Interval.Min != "1s" || report.Interval.Max != "3s" || report.Interval.Avg != "1.666666666s" {
+		t.Errorf("Unexpected intervals %v", report.Interval)
+	}
+	if report.Txs.Total != 6 || report.Txs.PerBlockMax != 4 {
+		t.Errorf("Unexpected txs %v", report.Txs)
+	}
+	if report.Rounds[0] != 1 || report.Rounds[1] != 1 || len(report.Rounds) != 2 {
+		t.Errorf("Unexpected rounds %v", report.Rounds)
+	}
+	val1 := report.Validators[1]
+	if val1.Signed != 1 || val1.Missed != 1 || val1.Participation != 0.5 || val1.MissedHeights[0] != 3 {
+		t.Errorf("Unexpected participation for val1: %v", val1)
+	}
+	if !strings.Contains(string(report.Markdown()), "| val1 | 1 | 1 | 50.0% |") {
+		t.Error("Expected val1 in the Markdown report")
+	}
+
+	// A commit by the set before a validator joined isn't blamed on the current validators
+	samples[0].ValidatorsHash, samples[0].Signed = "OLD", []bool{true, true}
+	samples[1].ValidatorsHash, samples[2].ValidatorsHash = "NEW", "NEW"
+	report = buildReport(samples, start, []string{"val0", "val1", "val2"}, "NEW")
+	if val2 := report.Validators[2]; val2.Signed != 1 || val2.Missed != 0 {
+		t.Errorf("Unexpected participation for val2: %v", val2)
+	}
+	if len(report.OtherSetHeights) != 1 || report.OtherSetHeights[0] != 2 {
+		t.Errorf("Expected height 2 to be signed by another set, got %v", report.OtherSetHeights)
+	}
+	if !strings.Contains(string(report.Markdown()), "1 commits between heights 2 and 2") {
+		t.Error("Expected the other set's commits in the Markdown report")
+	}
+}
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	. "github.com/tendermint/go-common"
+
+	"github.com/codegangsta/cli"
+)
+
+// The parts of a node's genesis.json that mintnet reads
+type genesisFile struct {
+	ChainID    string             `json:"chain_id"`
+	Validators []genesisValidator `json:"validators"`
+}
+
+type genesisValidator struct {
+	PubKey json.RawMessage `json:"pub_key"`
+	Amount int64           `json:"amount"`
+	Name   string          `json:"name"`
+}
+
+// Returns the machines in base with a core/genesis.json, sorted
+func initializedMachines(base string) ([]string, error) {
+	files, err := ioutil.ReadDir(base)
+	if err != nil {
+		return nil, err
+	}
+	machines := []string{}
+	for _, file := range files {
+		if file.IsDir() && FileExists(path.Join(base, file.Name(), "core", "genesis.json")) {
+			machines = append(machines, file.Name())
+		}
+	}
+	sort.Strings(machines)
+	return machines, nil
+}
+
+func genesisPath(base, mach string) string {
+	return path.Join(base, mach, "core", "genesis.json")
+}
+
+func readGenesis(base, mach string) (*genesisFile, []byte, error) {
+	genBytes, err := ioutil.ReadFile(genesisPath(base, mach))
+	if err != nil {
+		return nil, nil, err
+	}
+	genDoc := new(genesisFile)
+	if err := json.Unmarshal(genBytes, genDoc); err != nil {
+		return nil, nil, errors.New(Fmt("Invalid genesis.json for %v: %v", mach, err))
+	}
+	return genDoc, genBytes, nil
+}
+
+//--------------------------------------------------------------------------------
+
+// Add nodes to a running network.
+// The new machines get the chain's existing genesis.json and dial the running nodes,
+// so they join as non-validators without changing the chain-id.
+func cmdScaleOut(c *cli.Context) {
+	args := c.Args()
+	if len(args) != 2 {
+		cli.ShowAppHelp(c)
+		return
+	}
+	app := args[0]
+	base := args[1]
+	machines := ParseMachines(c.String("machines"))
+	randomPorts := c.Bool("publish-all")
+	noTMSP := c.Bool("no-tmsp")
+	proj := ParseProject(c, base)
+	if err := proj.ValidateAppMachines(machines); err != nil {
+		Exit(err.Error())
+	}
+
+	isNew := make(map[string]bool)
+	for _, mach := range machines {
+		isNew[mach] = true
+	}
+	initialized, err := initializedMachines(base)
+	if err != nil {
+		Exit(err.Error())
+	}
+	peers := []string{}
+	for _, mach := range initialized {
+		if !isNew[mach] {
+			peers = append(peers, mach)
+		}
+	}
+	if len(peers) == 0 {
+		Exit("No existing node with a core/genesis.json in " + base + ". Use init chain and start first")
+	}
+	genDoc, genBytes, err := readGenesis(base, peers[0])
+	if err != nil {
+		Exit(err.Error())
+	}
+
+	// Give each new machine the chain's genesis and its own priv_validator.json
+	for _, mach := range machines {
+		if err := initScaleOutDirectory(base, mach, genBytes); err != nil {
+			Exit(err.Error())
+		}
+	}
+	fmt.Println(Green(Fmt("Adding %v nodes to chain %v", len(machines), genDoc.ChainID)))
+
+	// Look up the running peers while the new nodes start
+	var peerAddrs []string
+	peersDone := make(chan struct{})
+	go func() {
+		defer close(peersDone)
+		for _, mach := range peers {
+			p2pAddr, _, err := getCoreAddrs(mach, app)
+			if err != nil {
+				fmt.Println(Yellow(Fmt("Skipping peer %v: %v", mach, err)))
+				continue
+			}
+			peerAddrs = append(peerAddrs, p2pAddr)
+		}
+	}()
+
+	coreInfos := make([]*CoreInfo, len(machines))
+	nodeStates := make([]*NodeState, len(machines))
+	results := fanOut(machines, c.Int("parallel"), func(res *machResult) {
+		coreInfo, err := startNode(res.Mach, app, base, proj, randomPorts, noTMSP)
+		if res.Step("start", err) != nil {
+			return
+		}
+		coreInfos[res.Index] = coreInfo
+		nodeState, err := getNodeState(res.Mach, app, proj, coreInfo)
+		if res.Step("inspect", err) == nil {
+			nodeStates[res.Index] = nodeState
+		}
+	})
+	<-peersDone
+	if len(peerAddrs) == 0 {
+		fmt.Println(Red("None of the existing nodes are reachable"))
+	}
+
+	// New nodes dial the existing peers and each other
+	seeds := peerAddrs
+	for _, coreInfo := range coreInfos {
+		if coreInfo != nil {
+			seeds = append(seeds, coreInfo.P2PAddr)
+		}
+	}
+	fmt.Println(Green("Instruct new nodes to dial the network"))
+	var wg sync.WaitGroup
+	for i, coreInfo := range coreInfos {
+		if coreInfo == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(res *machResult, rpcAddr string) {
+			defer wg.Done()
+			res.Step("dial peers", dialSeeds(rpcAddr, seeds))
+		}(results[i], coreInfo.RPCAddr)
+	}
+	wg.Wait()
+
+	if err := saveNetworkState(app, base, compactNodeStates(nodeStates), false); err != nil {
+		fmt.Println(Red("Failed to save network state: " + err.Error()))
+	}
+	exitWithResults(results)
+}
+
+// Creates mach's core directory with the chain's genesis.
+// A machine that already has a different genesis belongs to another chain.
+func initScaleOutDirectory(base, mach string, genBytes []byte) error {
+	if FileExists(genesisPath(base, mach)) {
+		oldBytes, err := ioutil.ReadFile(genesisPath(base, mach))
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(oldBytes, genBytes) {
+			return errors.New(Fmt("%v already has a genesis.json for another chain", mach))
+		}
+		return nil
+	}
+	if err := initMachCoreDirectory(base, mach); err != nil {
+		return err
+	}
+	return WriteFile(genesisPath(base, mach), genBytes, 0644)
+}
+
+//--------------------------------------------------------------------------------
+
+// Remove nodes from a running network.
+// Removing genesis validators warns, and is refused if the validators left online
+// would hold 2/3 of the voting power or less, as the chain would halt.
+func cmdScaleIn(c *cli.Context) {
+	args := c.Args()
+	if len(args) < 1 || len(args) > 2 {
+		cli.ShowAppHelp(c)
+		return
+	}
+	app := args[0]
+	machines := ParseMachines(c.String("machines"))
+	force := c.Bool("force")
+	base := ""
+	if len(args) == 2 {
+		base = args[1]
+	}
+	proj := parseNetworkProject(c, base)
+
+	if len(args) == 2 {
+		if err := checkScaleInPower(app, args[1], machines, force); err != nil {
+			Exit(err.Error())
+		}
+	} else if !force {
+		Exit("scale-in needs baseDir to check the validators' voting power. Use --force to skip the check")
+	}
+
+	// Stop services in reverse start order so nothing loses a dependency while running
+	results := fanOut(machines, c.Int("parallel"), func(res *machResult) {
+		mach := res.Mach
+		services, err := proj.NodeServices(mach)
+		if res.Step("services", err) != nil {
+			return
+		}
+		for i := len(services) - 1; i >= 0; i-- {
+			svc := services[i]
+			svcMach := proj.ServiceMachine(mach, svc)
+			if res.Step("stop "+svc.Name, stopService(svcMach, app, svc.Name)) != nil {
+				continue
+			}
+			// Stopped above, so forcing only spares us a failure if it was restarted meanwhile
+			res.Step("rm "+svc.Name, rmContainer(svcMach, svc.Container(app), true))
+		}
+		res.Step("rm common", rmContainer(mach, Fmt("%v_tmcommon", app), true))
+		if appMach := proj.AppMachine(mach); appMach != mach {
+			res.Step("rm common", rmContainer(appMach, Fmt("%v_tmcommon", app), true))
+		}
+		if c.Bool("destroy") {
+			res.Step("destroy", removeMachine(mach))
+		}
+	})
+	if len(args) == 2 {
+		if err := removeFromNetworkState(path.Join(args[1], NetworkFileName), succeededMachines(results)); err != nil {
+			fmt.Println(Red("Failed to update network state: " + err.Error()))
+		}
+	}
+	exitWithResults(results)
+}
+
+// Checks the voting power left online once machines are removed,
+// counting the validators of the other initialized machines in base whose core answers status
+func checkScaleInPower(app, base string, machines []string, force bool) error {
+	initialized, err := initializedMachines(base)
+	if err != nil {
+		return err
+	}
+	if len(initialized) == 0 {
+		return errors.New("No node with a core/genesis.json in " + base)
+	}
+	genDoc, _, err := readGenesis(base, initialized[0])
+	if err != nil {
+		return err
+	}
+	removed := make(map[string]bool)
+	for _, mach := range machines {
+		removed[mach] = true
+	}
+
+	// Map validators to machines by the pub key in each machine's priv_validator.json.
+	// A key may be on several machines, see init --duplicate-validator
+	valMachs := make(map[string][]string)
+	for _, mach := range initialized {
+		pubKey, err := readPrivValidatorPubKey(base, mach)
+		if err != nil {
+			fmt.Println(Yellow(Fmt("Cannot read priv_validator.json of %v: %v", mach, err)))
+			continue
+		}
+		valMachs[pubKey] = append(valMachs[pubKey], mach)
+	}
+
+	// Only the nodes that stay matter
+	remaining := []string{}
+	for _, mach := range initialized {
+		if !removed[mach] {
+			remaining = append(remaining, mach)
+		}
+	}
+	online := make(map[string]bool)
+	statuses := fanOut(remaining, 0, func(res *machResult) {
+		_, rpcAddr, err := getCoreAddrs(res.Mach, app)
+		if err == nil {
+			_, err = getCoreStatus(rpcAddr)
+		}
+		res.Step("status", err)
+	})
+	for _, res := range statuses {
+		online[res.Mach] = !res.Failed()
+	}
+
+	power := scaleInPower(genDoc, valMachs, online, removed)
+	for _, val := range power.Removed {
+		fmt.Println(Yellow(Fmt("Removing genesis validator %v", val)))
+	}
+	if len(power.Removed) == 0 {
+		return nil
+	}
+	fmt.Println(Fmt("Voting power online after removal: %v of %v", power.Online, power.Total))
+	if power.Safe() {
+		return nil
+	}
+	if force {
+		fmt.Println(Red("Continuing with --force, the chain will halt"))
+		return nil
+	}
+	return errors.New("Refusing to remove validators: the rest need more than 2/3 of the voting power online. Use --force to remove them anyway")
+}
+
+type votingPower struct {
+	Online  int64
+	Total   int64
+	Removed []string // names of removed validators
+}
+
+// The chain makes progress while more than 2/3 of the voting power is online
+func (vp votingPower) Safe() bool {
+	return vp.Online*3 > vp.Total*2
+}
+
+// Sums the genesis validators' power, once per key. A validator is online if one of its
+// machines stays and is online, and removed if all of its machines are removed
+func scaleInPower(genDoc *genesisFile, valMachs map[string][]string, online, removed map[string]bool) votingPower {
+	vp := votingPower{}
+	for _, val := range genDoc.Validators {
+		vp.Total += val.Amount
+		machs := valMachs[compactJSON(val.PubKey)]
+		if len(machs) == 0 {
+			continue
+		}
+		remaining := []string{}
+		for _, mach := range machs {
+			if !removed[mach] {
+				remaining = append(remaining, mach)
+			}
+		}
+		if len(remaining) == 0 {
+			name := val.Name
+			if name == "" {
+				name = strings.Join(machs, ",")
+			}
+			vp.Removed = append(vp.Removed, name)
+			continue
+		}
+		for _, mach := range remaining {
+			if online[mach] {
+				vp.Online += val.Amount
+				break
+			}
+		}
+	}
+	return vp
+}
+
+// Returns the compact json of the pub key in mach's priv_validator.json
+func readPrivValidatorPubKey(base, mach string) (string, error) {
+	privValBytes, err := ioutil.ReadFile(path.Join(base, mach, "core", "priv_validator.json"))
+	if err != nil {
+		return "", err
+	}
+	privVal := struct {
+		PubKey json.RawMessage `json:"pub_key"`
+	}{}
+	if err := json.Unmarshal(privValBytes, &privVal); err != nil {
+		return "", err
+	}
+	return compactJSON(privVal.PubKey), nil
+}
+
+func compactJSON(raw json.RawMessage) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return string(raw)
+	}
+	return buf.String()
+}
+
+// Returns the initialized machines in base whose priv_validator.json is a genesis validator
+func genesisValidatorMachines(base string) ([]string, error) {
+	initialized, err := initializedMachines(base)
+	if err != nil {
+		return nil, err
+	}
+	if len(initialized) == 0 {
+		return nil, errors.New("No node with a core/genesis.json in " + base)
+	}
+	genDoc, _, err := readGenesis(base, initialized[0])
+	if err != nil {
+		return nil, err
+	}
+	isVal := make(map[string]bool)
+	for _, val := range genDoc.Validators {
+		isVal[compactJSON(val.PubKey)] = true
+	}
+	machines := []string{}
+	for _, mach := range initialized {
+		pubKey, err := readPrivValidatorPubKey(base, mach)
+		if err == nil && isVal[pubKey] {
+			machines = append(machines, mach)
+		}
+	}
+	return machines, nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestScaleInPower(t *testing.T) {
+	genDoc := &genesisFile{Validators: []genesisValidator{
+		{PubKey: json.RawMessage(`[1, "AA"]`), Amount: 1, Name: "mach1"},
+		{PubKey: json.RawMessage(`[1, "BB"]`), Amount: 1, Name: "mach2"},
+		{PubKey: json.RawMessage(`[1, "CC"]`), Amount: 1, Name: "mach3"},
+		{PubKey: json.RawMessage(`[1, "DD"]`), Amount: 1, Name: "mach4"},
+	}}
+	valMachs := map[string][]string{`[1,"AA"]`: {"mach1"}, `[1,"BB"]`: {"mach2"}, `[1,"CC"]`: {"mach3"}, `[1,"DD"]`: {"mach4"}}
+	online := map[string]bool{"mach1": true, "mach2": true, "mach3": true, "mach4": true}
+
+	vp := scaleInPower(genDoc, valMachs, online, map[string]bool{"mach5": true})
+	if len(vp.Removed) != 0 || vp.Online != 4 || !vp.Safe() {
+		t.Errorf("Unexpected voting power %v", vp)
+	}
+
+	// 3 of 4 is more than 2/3
+	vp = scaleInPower(genDoc, valMachs, online, map[string]bool{"mach4": true})
+	if len(vp.Removed) != 1 || vp.Online != 3 || !vp.Safe() {
+		t.Errorf("Unexpected voting power %v", vp)
+	}
+
+	// with another node down only 2 of 4 are left
+	online["mach3"] = false
+	vp = scaleInPower(genDoc, valMachs, online, map[string]bool{"mach4": true})
+	if vp.Online != 2 || vp.Safe() {
+		t.Errorf("Unexpected voting power %v", vp)
+	}
+	// a duplicate of mach1's key counts once, and removing it leaves the validator
+	valMachs[`[1,"AA"]`] = []string{"mach1", "mach5"}
+	online["mach3"], online["mach5"] = true, true
+	vp = scaleInPower(genDoc, valMachs, online, map[string]bool{"mach5": true})
+	if len(vp.Removed) != 0 || vp.Online != 4 || vp.Total != 4 {
+		t.Errorf("Unexpected voting power %v", vp)
+	}
+	online["mach1"] = false
+	vp = scaleInPower(genDoc, valMachs, online, map[string]bool{"mach4": true})
+	if len(vp.Removed) != 1 || vp.Online != 3 {
+		t.Errorf("Expected the duplicate on mach5 to keep the validator online, got %v", vp)
+	}
+	vp = scaleInPower(genDoc, valMachs, online, map[string]bool{"mach1": true, "mach5": true})
+	if len(vp.Removed) != 1 || vp.Removed[0] != "mach1" {
+		t.Errorf("Expected removing both machines to remove the validator, got %v", vp)
+	}
+}
@@ -0,0 +1,421 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	. "github.com/tendermint/go-common"
+
+	"github.com/codegangsta/cli"
+	"gopkg.in/yaml.v2"
+)
+
+const defaultMaxHalt = time.Minute
+
+// A fault injection scenario, read from yaml. See the README for an example
+type Scenario struct {
+	App      string          `yaml:"app"`
+	Machines string          `yaml:"machines"`
+	BaseDir  string          `yaml:"base_dir"` // needed for random-validator targets
+	MaxHalt  string          `yaml:"max_halt"` // longest the chain may go without a new block
+	Duration string          `yaml:"duration"` // keep watching the chain until then
+	Seed     int64           `yaml:"seed"`     // for random targets, defaults to the time
+	Steps    []*ScenarioStep `yaml:"steps"`
+}
+
+type ScenarioStep struct {
+	At        string `yaml:"at"`
+	Action    string `yaml:"action"`    // stop, kill, pause, unpause or restart
+	Component string `yaml:"component"` // defaults to core
+	Target    string `yaml:"target"`    // a machine, random or random-validator
+	For       string `yaml:"for"`       // undo the action after this long
+}
+
+// An action at a time since the start, resolved from the steps
+type scenarioEvent struct {
+	At        time.Duration
+	Action    string
+	Component string
+	Target    string
+	undoOf    *scenarioEvent // the event this one undoes, whose machine it reuses
+	mach      string
+}
+
+type eventsByTime []*scenarioEvent
+
+func (e eventsByTime) Len() int           { return len(e) }
+func (e eventsByTime) Less(i, j int) bool { return e[i].At < e[j].At }
+func (e eventsByTime) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+
+// The outcome of an event, with the heights seen around it (-1 when the node didn't answer)
+type timelineEntry struct {
+	At            string         `json:"at"`
+	Action        string         `json:"action"`
+	Component     string         `json:"component"`
+	Mach          string         `json:"mach"`
+	HeightsBefore map[string]int `json:"heights_before"`
+	HeightsAfter  map[string]int `json:"heights_after"`
+	Error         string         `json:"error,omitempty"`
+}
+
+var undoActions = map[string]string{
+	"stop":  "restart",
+	"kill":  "restart",
+	"pause": "unpause",
+}
+
+func LoadScenario(file string) (*Scenario, error) {
+	scenarioBytes, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	scenario := new(Scenario)
+	if err := yaml.Unmarshal(scenarioBytes, scenario); err != nil {
+		return nil, errors.New(Fmt("Invalid scenario %v: %v", file, err))
+	}
+	return scenario, nil
+}
+
+// Resolves the steps into events sorted by time, adding an undo event for each step with "for"
+func (s *Scenario) Events() ([]*scenarioEvent, error) {
+	events := []*scenarioEvent{}
+	for i, step := range s.Steps {
+		at, err := time.ParseDuration(step.At)
+		if err != nil {
+			return nil, errors.New(Fmt("Invalid time %q in step %v", step.At, i+1))
+		}
+		switch step.Action {
+		case "stop", "kill", "pause", "unpause", "restart":
+		default:
+			return nil, errors.New(Fmt("Unknown action %q in step %v", step.Action, i+1))
+		}
+		if step.Target == "" {
+			return nil, errors.New(Fmt("No target in step %v", i+1))
+		}
+		component := step.Component
+		if component == "" {
+			component = "core"
+		}
+		event := &scenarioEvent{At: at, Action: step.Action, Component: component, Target: step.Target}
+		events = append(events, event)
+
+		if step.For == "" {
+			continue
+		}
+		undo, ok := undoActions[step.Action]
+		if !ok {
+			return nil, errors.New(Fmt("Action %v in step %v cannot be undone", step.Action, i+1))
+		}
+		forDuration, err := time.ParseDuration(step.For)
+		if err != nil {
+			return nil, errors.New(Fmt("Invalid duration %q in step %v", step.For, i+1))
+		}
+		events = append(events, &scenarioEvent{At: at + forDuration, Action: undo, Component: component,
+			Target: step.Target, undoOf: event})
+	}
+	sort.Stable(eventsByTime(events))
+	return events, nil
+}
+
+//--------------------------------------------------------------------------------
+
+// Run a fault injection scenario against a running network.
+// Prints the timeline, and exits non-zero if a step failed or the chain halted too long.
+func cmdChaosRun(c *cli.Context) {
+	args := c.Args()
+	if len(args) != 1 {
+		cli.ShowAppHelp(c)
+		return
+	}
+	scenario, err := LoadScenario(args[0])
+	if err != nil {
+		Exit(err.Error())
+	}
+	events, err := scenario.Events()
+	if err != nil {
+		Exit(err.Error())
+	}
+	maxHalt := defaultMaxHalt
+	if scenario.MaxHalt != "" {
+		if maxHalt, err = time.ParseDuration(scenario.MaxHalt); err != nil {
+			Exit("Invalid max_halt " + scenario.MaxHalt)
+		}
+	}
+	duration := time.Duration(0)
+	if scenario.Duration != "" {
+		if duration, err = time.ParseDuration(scenario.Duration); err != nil {
+			Exit("Invalid duration " + scenario.Duration)
+		}
+	}
+	if len(events) > 0 && events[len(events)-1].At > duration {
+		duration = events[len(events)-1].At
+	}
+
+	machines, state := parseNetworkMachines(c)
+	if scenario.Machines != "" && !c.IsSet("machines") {
+		machines = ParseMachines(scenario.Machines)
+	}
+	app := scenario.App
+	if app == "" && state != nil {
+		app = state.App
+	}
+	if app == "" {
+		Exit("The scenario needs an app")
+	}
+	proj := parseNetworkProject(c, scenario.BaseDir)
+
+	validators := machines
+	if scenario.BaseDir != "" {
+		if validators, err = genesisValidatorMachines(scenario.BaseDir); err != nil {
+			Exit(err.Error())
+		}
+	}
+	seed := scenario.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	runner := &scenarioRunner{
+		app:        app,
+		proj:       proj,
+		machines:   machines,
+		validators: validators,
+		rand:       rand.New(rand.NewSource(seed)),
+		faulted:    make(map[string]bool),
+		rpcAddrs:   make(map[string]string),
+	}
+	for _, mach := range machines {
+		if runner.rpcAddrs[mach], err = getRPCAddr(state, mach, app); err != nil {
+			Exit(err.Error())
+		}
+	}
+	fmt.Println(Green(Fmt("Running %v events over %v with seed %v", len(events), duration, seed)))
+
+	// Watch the chain in the background for the whole run
+	monitor := newHaltMonitor()
+	done := make(chan struct{})
+	monitorDone := make(chan struct{})
+	go func() {
+		defer close(monitorDone)
+		monitor.run(runner.observeHeights, done)
+	}()
+
+	start := time.Now()
+	timeline := []*timelineEntry{}
+	for _, event := range events {
+		time.Sleep(start.Add(event.At).Sub(time.Now()))
+		timeline = append(timeline, runner.runEvent(event))
+	}
+	time.Sleep(start.Add(duration).Sub(time.Now()))
+	close(done)
+	<-monitorDone
+
+	printTimeline(timeline)
+	if file := c.String("timeline"); file != "" {
+		timelineBytes, _ := json.MarshalIndent(timeline, "", "  ")
+		if err := WriteFile(file, timelineBytes, 0644); err != nil {
+			fmt.Println(Red(err.Error()))
+		}
+	}
+
+	failed := false
+	for _, entry := range timeline {
+		if entry.Error != "" {
+			failed = true
+		}
+	}
+	longestHalt := monitor.longestHalt()
+	fmt.Println(Fmt("Longest time without a new block: %v (max %v)", longestHalt, maxHalt))
+	if longestHalt > maxHalt {
+		Exit(Fmt("Chain halted for %v, longer than %v", longestHalt, maxHalt))
+	}
+	if failed {
+		Exit("Some steps failed")
+	}
+	fmt.Println(Green("Scenario passed"))
+}
+
+type scenarioRunner struct {
+	app        string
+	proj       *Project
+	machines   []string
+	validators []string
+	rand       *rand.Rand
+	faulted    map[string]bool // machines we took down and didn't bring back
+	rpcAddrs   map[string]string
+}
+
+func (r *scenarioRunner) runEvent(event *scenarioEvent) *timelineEntry {
+	entry := &timelineEntry{
+		At:        event.At.String(),
+		Action:    event.Action,
+		Component: event.Component,
+	}
+	mach, err := r.resolveTarget(event)
+	if err != nil {
+		entry.Error = err.Error()
+		fmt.Println(Red(Fmt("%v %v: %v", entry.At, event.Action, err)))
+		return entry
+	}
+	event.mach = mach
+	entry.Mach = mach
+	fmt.Println(Blue(Fmt("%v %v %v on %v", entry.At, event.Action, event.Component, mach)))
+
+	entry.HeightsBefore = r.observeHeights()
+	if err := r.applyAction(mach, event.Action, event.Component); err != nil {
+		entry.Error = err.Error()
+	} else if _, ok := undoActions[event.Action]; ok {
+		r.faulted[mach] = true
+	} else {
+		delete(r.faulted, mach)
+	}
+	entry.HeightsAfter = r.observeHeights()
+	return entry
+}
+
+// Random targets pick among the machines we haven't already taken down
+func (r *scenarioRunner) resolveTarget(event *scenarioEvent) (string, error) {
+	if event.undoOf != nil {
+		if event.undoOf.mach == "" {
+			return "", errors.New("the step it undoes failed")
+		}
+		return event.undoOf.mach, nil
+	}
+	candidates := []string{}
+	switch event.Target {
+	case "random":
+		candidates = r.machines
+	case "random-validator":
+		candidates = r.validators
+	default:
+		return event.Target, nil
+	}
+	healthy := []string{}
+	for _, mach := range candidates {
+		if !r.faulted[mach] {
+			healthy = append(healthy, mach)
+		}
+	}
+	if len(healthy) == 0 {
+		return "", errors.New("no machine left for target " + event.Target)
+	}
+	return healthy[r.rand.Intn(len(healthy))], nil
+}
+
+func (r *scenarioRunner) applyAction(mach, action, component string) error {
+	services, err := r.proj.NodeServices(mach)
+	if err != nil {
+		return err
+	}
+	svc := findService(services, component)
+	if svc == nil {
+		return errors.New(Fmt("Unknown component %v on machine %v", component, mach))
+	}
+	svcMach := r.proj.ServiceMachine(mach, svc)
+	switch action {
+	case "stop":
+		return stopService(svcMach, r.app, component)
+	case "kill":
+		return killService(svcMach, r.app, component)
+	case "pause":
+		return pauseService(svcMach, r.app, component, true)
+	case "unpause":
+		return pauseService(svcMach, r.app, component, false)
+	case "restart":
+		return restartService(svcMach, r.app, component)
+	}
+	return errors.New("Unknown action " + action)
+}
+
+// Returns each node's latest height, -1 if it didn't answer
+func (r *scenarioRunner) observeHeights() map[string]int {
+	heights := make(map[string]int)
+	var mtx sync.Mutex
+	fanOut(r.machines, 0, func(res *machResult) {
+		height := -1
+		if status, err := getCoreStatus(r.rpcAddrs[res.Mach]); err == nil {
+			height = status.LatestBlockHeight
+		}
+		mtx.Lock()
+		heights[res.Mach] = height
+		mtx.Unlock()
+	})
+	return heights
+}
+
+//--------------------------------------------------------------------------------
+
+// Tracks the longest time the highest height seen didn't increase
+type haltMonitor struct {
+	mtx          sync.Mutex
+	maxHeight    int
+	lastProgress time.Time
+	longest      time.Duration
+}
+
+func newHaltMonitor() *haltMonitor {
+	return &haltMonitor{maxHeight: -1, lastProgress: time.Now()}
+}
+
+func (m *haltMonitor) run(observe func() map[string]int, done chan struct{}) {
+	for {
+		m.observe(observe(), time.Now())
+		select {
+		case <-done:
+			return
+		case <-time.After(time.Second * 2):
+		}
+	}
+}
+
+func (m *haltMonitor) observe(heights map[string]int, now time.Time) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	for _, height := range heights {
+		if height > m.maxHeight {
+			m.maxHeight = height
+			m.lastProgress = now
+		}
+	}
+	if halt := now.Sub(m.lastProgress); halt > m.longest {
+		m.longest = halt
+	}
+}
+
+func (m *haltMonitor) longestHalt() time.Duration {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return m.longest
+}
+
+func printTimeline(timeline []*timelineEntry) {
+	fmt.Println("")
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "AT\tACTION\tCOMPONENT\tMACHINE\tMAX HEIGHT BEFORE\tMAX HEIGHT AFTER\tRESULT")
+	for _, entry := range timeline {
+		result := "ok"
+		if entry.Error != "" {
+			result = "FAIL: " + entry.Error
+		}
+		fmt.Fprintln(w, Fmt("%v\t%v\t%v\t%v\t%v\t%v\t%v", entry.At, entry.Action, entry.Component, entry.Mach,
+			maxHeight(entry.HeightsBefore), maxHeight(entry.HeightsAfter), result))
+	}
+	w.Flush()
+}
+
+func maxHeight(heights map[string]int) int {
+	max := -1
+	for _, height := range heights {
+		if height > max {
+			max = height
+		}
+	}
+	return max
+}
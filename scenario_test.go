@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestScenarioEvents(t *testing.T) {
+	scenarioYAML := `
+app: mytest
+steps:
+  - {at: 10s, action: kill, target: random-validator, for: 30s}
+  - {at: 20s, action: pause, component: app, target: mach3, for: 5s}
+`
+	scenario := new(Scenario)
+	if err := yaml.Unmarshal([]byte(scenarioYAML), scenario); err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	events, err := scenario.Events()
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	expected := []struct {
+		at     time.Duration
+		action string
+	}{{10 * time.Second, "kill"}, {20 * time.Second, "pause"}, {25 * time.Second, "unpause"}, {40 * time.Second, "restart"}}
+	if len(events) != len(expected) {
+		t.Fatalf("Expected %v events, got %v", len(expected), len(events))
+	}
+	for i, event := range events {
+		if event.At != expected[i].at || event.Action != expected[i].action {
+			t.Errorf("Expected %v at %v, got %v at %v", expected[i].action, expected[i].at, event.Action, event.At)
+		}
+	}
+	if events[3].undoOf != events[0] || events[0].Component != "core" {
+		t.Error("Expected the restart to undo the kill of core")
+	}
+
+	scenario.Steps = []*ScenarioStep{{At: "1s", Action: "restart", Target: "mach1", For: "1s"}}
+	if _, err := scenario.Events(); err == nil {
+		t.Error("Expected error for undoing a restart")
+	}
+}
+
+func TestHaltMonitor(t *testing.T) {
+	start := time.Now()
+	m := &haltMonitor{maxHeight: -1, lastProgress: start}
+	m.observe(map[string]int{"mach1": 5, "mach2": -1}, start.Add(time.Second))
+	m.observe(map[string]int{"mach1": 5, "mach2": 4}, start.Add(11*time.Second))
+	m.observe(map[string]int{"mach1": 6}, start.Add(12*time.Second))
+	if halt := m.longestHalt(); halt != 10*time.Second {
+		t.Errorf("Expected a halt of 10s, got %v", halt)
+	}
+}
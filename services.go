@@ -0,0 +1,293 @@
+package main
+
+import (
+	"errors"
+	"path"
+
+	. "github.com/tendermint/go-common"
+)
+
+// A service is one container in a node, named <app>_tm<name>.
+// Every node runs data, app and core unless told otherwise.
+// The project file can add services, override these by name, or disable them.
+type Service struct {
+	Name string `json:"name"`
+
+	// Directory under baseDir copied to /data/tendermint/<dir>. Defaults to name
+	Dir string `json:"dir,omitempty"`
+
+	// Script run in the container. Defaults to /data/tendermint/<dir>/init.sh
+	Init string `json:"init,omitempty"`
+
+	// Services that must be ready before this one starts
+	DependsOn []string `json:"depends_on,omitempty"`
+
+	Disabled bool `json:"disabled,omitempty"`
+
+	// Image, readiness probe and docker run options, overridden by "components"
+	ComponentConfig
+}
+
+// Building software in init.sh can take a while, so data, app and core wait longer than the default.
+// The app's probe depends on the proxy app, see appReadyCheck.
+var defaultServices = []Service{
+	{Name: "data", ComponentConfig: ComponentConfig{
+		Ready: &ReadyCheck{Unix: "/data/tendermint/data/data.sock", Timeout: 600},
+	}},
+	{Name: "app", DependsOn: []string{"data"}},
+	{Name: "core", DependsOn: []string{"app"}, ComponentConfig: ComponentConfig{
+		Ready: &ReadyCheck{RPC: "46657", Timeout: 600},
+	}},
+}
+
+func (svc *Service) Container(app string) string {
+	return Fmt("%v_tm%v", app, svc.Name)
+}
+
+func (svc *Service) DirName() string {
+	if svc.Dir != "" {
+		return svc.Dir
+	}
+	return svc.Name
+}
+
+func (svc *Service) InitScript() string {
+	if svc.Init != "" {
+		return svc.Init
+	}
+	return path.Join("/data/tendermint", svc.DirName(), "init.sh")
+}
+
+// data and app run where the app runs, as they share a socket volume.
+// Neither is started with --no-tmsp.
+func (svc *Service) OnAppMachine() bool {
+	return svc.Name == "data" || svc.Name == "app"
+}
+
+//--------------------------------------------------------------------------------
+
+// Overrides the settings other sets, keeping the rest, like a default service's probe
+func (svc *Service) merge(other *Service) {
+	if other.Dir != "" {
+		svc.Dir = other.Dir
+	}
+	if other.Init != "" {
+		svc.Init = other.Init
+	}
+	if other.DependsOn != nil {
+		svc.DependsOn = other.DependsOn
+	}
+	if other.Disabled {
+		svc.Disabled = true
+	}
+	// Copy the lists and maps merge adds to, as they may belong to the project
+	svc.Ulimits = append([]string{}, svc.Ulimits...)
+	svc.Volumes = append([]string{}, svc.Volumes...)
+	svc.DockerArgs = append([]string{}, svc.DockerArgs...)
+	svc.Labels = mergeStringMaps(nil, svc.Labels)
+	svc.Env = mergeStringMaps(nil, svc.Env)
+	svc.ComponentConfig.merge(&other.ComponentConfig)
+}
+
+// Returns the services for mach in the order they should be started.
+// Services listed for the machine are merged into the global ones of the same name,
+// which are merged into the defaults.
+func (p *Project) NodeServices(mach string) ([]*Service, error) {
+	services := []*Service{}
+	byName := make(map[string]*Service)
+	add := func(svc Service) {
+		if old, ok := byName[svc.Name]; ok {
+			old.merge(&svc)
+			return
+		}
+		byName[svc.Name] = &svc
+		services = append(services, &svc)
+	}
+	for _, svc := range defaultServices {
+		add(svc)
+	}
+	if p != nil {
+		for _, svc := range p.Services {
+			add(svc)
+		}
+		if machCfg, ok := p.Machines[mach]; ok {
+			for _, svc := range machCfg.Services {
+				add(svc)
+			}
+		}
+	}
+
+	enabled := []*Service{}
+	for _, svc := range services {
+		if svc.Name == "" {
+			return nil, errors.New("Service without a name")
+		}
+		if svc.Disabled {
+			if svc.Name == "core" {
+				return nil, errors.New("The core service cannot be disabled")
+			}
+			continue
+		}
+		enabled = append(enabled, svc)
+	}
+	return sortServices(enabled, byName)
+}
+
+// Topologically sorts services by their dependencies, keeping the declared order otherwise.
+// Dependencies on disabled services are ignored.
+func sortServices(services []*Service, byName map[string]*Service) ([]*Service, error) {
+	sorted := []*Service{}
+	done := make(map[string]bool)
+	visiting := make(map[string]bool)
+	var visit func(svc *Service) error
+	visit = func(svc *Service) error {
+		if done[svc.Name] {
+			return nil
+		}
+		if visiting[svc.Name] {
+			return errors.New("Dependency cycle at service " + svc.Name)
+		}
+		visiting[svc.Name] = true
+		for _, depName := range svc.DependsOn {
+			dep, ok := byName[depName]
+			if !ok {
+				return errors.New(Fmt("Service %v depends on unknown service %v", svc.Name, depName))
+			}
+			if dep.Disabled {
+				continue
+			}
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		visiting[svc.Name] = false
+		done[svc.Name] = true
+		sorted = append(sorted, svc)
+		return nil
+	}
+	for _, svc := range services {
+		if err := visit(svc); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+// Returns the services that transitively depend on name, in start order
+func dependentServices(services []*Service, name string) []*Service {
+	affected := map[string]bool{name: true}
+	dependents := []*Service{}
+	// services are sorted, so dependencies come first
+	for _, svc := range services {
+		for _, dep := range svc.DependsOn {
+			if affected[dep] {
+				affected[svc.Name] = true
+				dependents = append(dependents, svc)
+				break
+			}
+		}
+	}
+	return dependents
+}
+
+func findService(services []*Service, name string) *Service {
+	for _, svc := range services {
+		if svc.Name == name {
+			return svc
+		}
+	}
+	return nil
+}
+
+// Returns the machine that runs svc for the node on mach
+func (p *Project) ServiceMachine(mach string, svc *Service) string {
+	if svc.OnAppMachine() {
+		return p.AppMachine(mach)
+	}
+	return mach
+}
+
+//--------------------------------------------------------------------------------
+
+// Starts a service on mach and waits for it to be ready.
+// Not for core, which is started by startTMCore.
+func startService(mach, app string, proj *Project, svc *Service) error {
+	cfg := proj.Component(mach, svc.Name)
+	extraOpts := ""
+	if svc.Name == "app" {
+		appAddr := "unix:///data/tendermint/app/app.sock"
+		if proj.ProxyApp == "tcp" || proj.ProxyApp == "grpc" {
+			appAddr = "tcp://0.0.0.0:46658"
+			// apps serving a tmcore on another machine publish their port
+			if proj.IsRemoteAppMachine(mach) {
+				extraOpts += "-p 46658:46658 "
+			}
+		}
+		extraOpts += Fmt(`-e APPADDR="%v" -e TMSP="%v" `, eB(appAddr), eB(tmspConnType(proj)))
+	}
+	args := []string{"ssh", mach, Fmt(`docker run --name %v --volumes-from %v_tmcommon -d %v`+
+		`-e TMNAME="%v" %v%v`,
+		svc.Container(app), app, extraOpts,
+		eB(mach), cfg.RunOpts(), componentCmd(cfg, svc.InitScript()))}
+	if !runProcess(Fmt("start-tm%v-%v", svc.Name, mach), "docker-machine", args, true) {
+		return errors.New(Fmt("Failed to start tm%v on machine %v", svc.Name, mach))
+	}
+	return waitReady(mach, svc.Container(app), proj.ReadyCheck(mach, svc.Name))
+}
+
+// Returns the readiness probe for the service on mach
+func (p *Project) ReadyCheck(mach, name string) *ReadyCheck {
+	if check := p.Component(mach, name).Ready; check != nil {
+		return check
+	}
+	if name == "app" {
+		return appReadyCheck(p)
+	}
+	return nil
+}
+
+// The app is ready once it listens where tmcore will connect
+func appReadyCheck(proj *Project) *ReadyCheck {
+	if proj.ProxyApp == "tcp" || proj.ProxyApp == "grpc" {
+		return &ReadyCheck{TCP: "46658", Timeout: 600}
+	}
+	return &ReadyCheck{Unix: "/data/tendermint/app/app.sock", Timeout: 600}
+}
+
+func stopService(mach, app, name string) error {
+	args := []string{"ssh", mach, Fmt(`docker stop %v_tm%v`, app, name)}
+	if !runProcess(Fmt("stop-tm%v-%v", name, mach), "docker-machine", args, true) {
+		return errors.New(Fmt("Failed to stop tm%v on machine %v", name, mach))
+	}
+	return nil
+}
+
+func restartService(mach, app, name string) error {
+	args := []string{"ssh", mach, Fmt(`docker start %v_tm%v`, app, name)}
+	if !runProcess(Fmt("restart-tm%v-%v", name, mach), "docker-machine", args, true) {
+		return errors.New(Fmt("Failed to restart tm%v on machine %v", name, mach))
+	}
+	return nil
+}
+
+// Kills the service's container without letting it shut down cleanly
+func killService(mach, app, name string) error {
+	args := []string{"ssh", mach, Fmt(`docker kill %v_tm%v`, app, name)}
+	if !runProcess(Fmt("kill-tm%v-%v", name, mach), "docker-machine", args, true) {
+		return errors.New(Fmt("Failed to kill tm%v on machine %v", name, mach))
+	}
+	return nil
+}
+
+// Freezes or thaws the service's processes
+func pauseService(mach, app, name string, pause bool) error {
+	cmd := "pause"
+	if !pause {
+		cmd = "unpause"
+	}
+	args := []string{"ssh", mach, Fmt(`docker %v %v_tm%v`, cmd, app, name)}
+	if !runProcess(Fmt("%v-tm%v-%v", cmd, name, mach), "docker-machine", args, true) {
+		return errors.New(Fmt("Failed to %v tm%v on machine %v", cmd, name, mach))
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"testing"
+)
+
+func serviceNames(services []*Service) string {
+	names := ""
+	for i, svc := range services {
+		if i > 0 {
+			names += ","
+		}
+		names += svc.Name
+	}
+	return names
+}
+
+func TestNodeServices(t *testing.T) {
+	var proj *Project
+	services, err := proj.NodeServices("mach1")
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if names := serviceNames(services); names != "data,app,core" {
+		t.Errorf("Expected default services, got %v", names)
+	}
+
+	proj = &Project{
+		Services: []Service{
+			{Name: "indexer", DependsOn: []string{"core"}},
+			{Name: "signer"},
+			{Name: "core", DependsOn: []string{"app", "signer"}},
+		},
+		Machines: map[string]*MachineConfig{
+			"mach2": &MachineConfig{
+				Services: []Service{{Name: "data", Disabled: true}},
+			},
+		},
+	}
+	services, err = proj.NodeServices("mach1")
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if names := serviceNames(services); names != "data,app,signer,core,indexer" {
+		t.Errorf("Unexpected service order %v", names)
+	}
+	if names := serviceNames(dependentServices(services, "signer")); names != "core,indexer" {
+		t.Errorf("Unexpected dependents %v", names)
+	}
+
+	services, err = proj.NodeServices("mach2")
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if names := serviceNames(services); names != "app,signer,core,indexer" {
+		t.Errorf("Unexpected services for mach2 %v", names)
+	}
+
+	proj.Services = append(proj.Services, Service{Name: "signer", DependsOn: []string{"indexer"}})
+	if _, err := proj.NodeServices("mach1"); err == nil {
+		t.Error("Expected error for dependency cycle")
+	}
+}
+
+func TestNodeServicesMerge(t *testing.T) {
+	proj := &Project{
+		Services: []Service{
+			{Name: "core", ComponentConfig: ComponentConfig{Image: "tendermint/tendermint", Env: map[string]string{"A": "1"}}},
+		},
+		Machines: map[string]*MachineConfig{
+			"mach2": &MachineConfig{
+				Services: []Service{{Name: "core", ComponentConfig: ComponentConfig{Env: map[string]string{"B": "2"}}}},
+			},
+		},
+	}
+	services, err := proj.NodeServices("mach2")
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	core := findService(services, "core")
+	if core.Image != "tendermint/tendermint" || core.Ready == nil || core.Ready.RPC != "46657" {
+		t.Errorf("Expected the image override to keep the default probe, got %v", core)
+	}
+	if len(core.DependsOn) != 1 || core.DependsOn[0] != "app" {
+		t.Errorf("Expected the default dependencies, got %v", core.DependsOn)
+	}
+	if core.Env["A"] != "1" || core.Env["B"] != "2" {
+		t.Errorf("Expected merged env, got %v", core.Env)
+	}
+	if len(proj.Services[0].Env) != 1 {
+		t.Errorf("Expected the project's env to be left alone, got %v", proj.Services[0].Env)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	. "github.com/tendermint/go-common"
+
+	"github.com/codegangsta/cli"
+)
+
+// Moves the cursor home and clears the terminal
+const clearScreen = "\033[H\033[2J"
+
+// Show a refreshing table of every node's containers, height, peers, mempool and rpc latency.
+// Nodes that are down are red, nodes more than one block behind the highest are yellow.
+func cmdTop(c *cli.Context) {
+	args := c.Args()
+	machines, state := parseNetworkMachines(c)
+	app, ok := parseNetworkApp(args, state)
+	if !ok {
+		cli.ShowAppHelp(c)
+		return
+	}
+	proj := parseNetworkProject(c, "")
+	watcher := newNodeWatcher(app, proj, state, machines)
+	interval := c.Duration("interval")
+	for {
+		snapshots := watcher.poll(!c.Bool("no-containers"))
+		now := time.Now()
+		fmt.Print(clearScreen)
+		fmt.Println(Fmt("%v on %v nodes at %v, refreshing every %v", app, len(machines), now.Format("15:04:05"), interval))
+		fmt.Println("")
+		os.Stdout.Write(renderTop(snapshots, now))
+		time.Sleep(interval)
+	}
+}
+
+// Renders the table, coloring whole lines so the colors don't upset the column widths
+func renderTop(snapshots []*nodeSnapshot, now time.Time) []byte {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "MACHINE\tCONTAINERS\tHEIGHT\tLAST BLOCK\tPEERS\tMEMPOOL\tRPC")
+	maxHeight := maxSnapshotHeight(snapshots)
+	for _, snap := range snapshots {
+		containers := topContainers(snap.Containers)
+		if !snap.RPCUp {
+			fmt.Fprintln(w, Fmt("%v\t%v\t-\t-\t-\t-\tdown", snap.Mach, containers))
+			continue
+		}
+		lastBlock := now.Sub(snap.BlockTime) / time.Second * time.Second
+		fmt.Fprintln(w, Fmt("%v\t%v\t%v\t%v ago\t%v\t%v\t%v", snap.Mach, containers, snap.Height, lastBlock,
+			snap.Peers, snap.MempoolSize, snap.RPCLatency/time.Millisecond*time.Millisecond))
+	}
+	w.Flush()
+
+	lines := strings.SplitAfter(buf.String(), "\n")
+	out := lines[0]
+	for i, snap := range snapshots {
+		line := strings.TrimSuffix(lines[i+1], "\n")
+		switch nodeHealth(snap, maxHeight) {
+		case "down":
+			line = Red(line)
+		case "lagging":
+			line = Yellow(line)
+		}
+		out += line + "\n"
+	}
+	return []byte(out)
+}
+
+// Returns "down" if the node's rpc didn't answer, "lagging" if it's more than one block behind, or "ok"
+func nodeHealth(snap *nodeSnapshot, maxHeight int) string {
+	switch {
+	case !snap.RPCUp:
+		return "down"
+	case snap.Height+1 < maxHeight:
+		return "lagging"
+	}
+	return "ok"
+}
+
+// Lists the containers by component, e.g. "app:running core:exited(2)" with the restart count
+func topContainers(containers map[string]containerState) string {
+	if containers == nil {
+		return "-"
+	}
+	components := []string{}
+	for component := range containers {
+		components = append(components, component)
+	}
+	sort.Strings(components)
+	parts := []string{}
+	for _, component := range components {
+		state := containers[component]
+		part := component + ":" + state.Status
+		if state.Restarts > 0 {
+			part += Fmt("(%v)", state.Restarts)
+		}
+		parts = append(parts, part)
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderTop(t *testing.T) {
+	now := time.Now()
+	snapshots := []*nodeSnapshot{
+		{Mach: "mach1", RPCUp: true, Height: 10, BlockTime: now.Add(-3 * time.Second), Peers: 2, Containers: map[string]containerState{
+			"core": {Status: "running", Restarts: 1},
+			"app":  {Status: "running"},
+		}},
+		{Mach: "mach2", RPCUp: true, Height: 7, BlockTime: now.Add(-20 * time.Second)},
+		{Mach: "mach3"},
+	}
+	lines := strings.Split(strings.TrimSuffix(string(renderTop(snapshots, now)), "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Expected a header and 3 rows, got %v", lines)
+	}
+	if !strings.Contains(lines[1], "app:running core:running(1)") || !strings.Contains(lines[1], "3s ago") {
+		t.Errorf("Unexpected row %q", lines[1])
+	}
+	if !strings.Contains(lines[2], "20s ago") || !strings.Contains(lines[3], "down") {
+		t.Errorf("Unexpected rows %q and %q", lines[2], lines[3])
+	}
+	for i, expected := range []string{"ok", "lagging", "down"} {
+		if health := nodeHealth(snapshots[i], 10); health != expected {
+			t.Errorf("Expected %v to be %v, got %v", snapshots[i].Mach, expected, health)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	. "github.com/tendermint/go-common"
+
+	"github.com/codegangsta/cli"
+)
+
+//--------------------------------------------------------------------------------
+
+// Push a component's new init.sh and recreate its container, one machine at a time.
+// We wait for each upgraded node to catch up before moving on to the next machine.
+func cmdUpgrade(c *cli.Context) {
+	args := c.Args()
+	if len(args) != 2 {
+		cli.ShowAppHelp(c)
+		return
+	}
+	app := args[0]
+	base := args[1]
+	machines := ParseMachines(c.String("machines"))
+	component := c.String("component")
+	randomPorts := c.Bool("publish-all")
+	noTMSP := c.Bool("no-tmsp")
+	timeout := c.Duration("timeout")
+	proj := ParseProject(c, base)
+	if err := proj.ValidateAppMachines(machines); err != nil {
+		Exit(err.Error())
+	}
+
+	if noTMSP && (component == "data" || component == "app") {
+		Exit(Fmt("Cannot upgrade %v with --no-tmsp", component))
+	}
+
+	for i, mach := range machines {
+		fmt.Println(Green(Fmt("Upgrading %v on %v (%v/%v)", component, mach, i+1, len(machines))))
+		peers := make([]string, 0, len(machines)-1)
+		for _, other := range machines {
+			if other != mach {
+				peers = append(peers, other)
+			}
+		}
+		coreRestarted, err := upgradeMachine(mach, app, base, proj, component, peers, randomPorts, noTMSP)
+		if err != nil {
+			Exit(err.Error())
+		}
+		// The timeout covers both waits
+		deadline := time.Now().Add(timeout)
+		if coreRestarted {
+			// The core's configured probe may pass on an earlier container's logs,
+			// so make sure the new one serves rpc before measuring its height
+			coreReady := &ReadyCheck{RPC: "46657", Timeout: remainingSeconds(deadline)}
+			if err := waitReady(mach, app+"_tmcore", coreReady); err != nil {
+				Exit(err.Error())
+			}
+		}
+		if err := waitForCatchUp(mach, app, peers, deadline.Sub(time.Now())); err != nil {
+			Exit(err.Error())
+		}
+	}
+
+	fmt.Println(Green(Fmt("Done upgrading %v for %v", component, app)))
+}
+
+// Returns whether the core was restarted, either as the component or as one of its dependents
+func upgradeMachine(mach, app, base string, proj *Project, component string, peers []string, randomPorts, noTMSP bool) (bool, error) {
+	services, err := proj.NodeServices(mach)
+	if err != nil {
+		return false, err
+	}
+	svc := findService(services, component)
+	if svc == nil {
+		return false, errors.New(Fmt("Unknown service %v on machine %v", component, mach))
+	}
+	svcMach := proj.ServiceMachine(mach, svc)
+	err = copyServiceDir(svcMach, app, base, proj, svc)
+	if err != nil {
+		return false, err
+	}
+
+	// Services that depend on this one lose their connection to it,
+	// so bring them down around the upgrade.
+	dependents := dependentServices(services, component)
+	for i := len(dependents) - 1; i >= 0; i-- {
+		dep := dependents[i]
+		if err := stopService(proj.ServiceMachine(mach, dep), app, dep.Name); err != nil {
+			return false, err
+		}
+	}
+
+	if err := rmContainer(svcMach, svc.Container(app), true); err != nil {
+		return false, err
+	}
+	if component == "core" {
+		// The new node dials the other nodes directly, since there's
+		// nobody around to instruct it with dial_seeds.
+		seeds := []string{}
+		for _, peer := range peers {
+			p2pAddr, _, err := getCoreAddrs(peer, app)
+			if err != nil {
+				fmt.Println(Yellow(err.Error()))
+				continue
+			}
+			seeds = append(seeds, p2pAddr)
+		}
+		if _, err := startTMCore(mach, app, proj, seeds, randomPorts, noTMSP); err != nil {
+			return false, err
+		}
+	} else {
+		if err := startService(svcMach, app, proj, svc); err != nil {
+			return false, err
+		}
+	}
+
+	coreRestarted := component == "core"
+	for _, dep := range dependents {
+		coreRestarted = coreRestarted || dep.Name == "core"
+		depMach := proj.ServiceMachine(mach, dep)
+		if err := restartService(depMach, app, dep.Name); err != nil {
+			return false, err
+		}
+		if err := waitReady(depMach, dep.Container(app), proj.ReadyCheck(mach, dep.Name)); err != nil {
+			return false, err
+		}
+	}
+	return coreRestarted, nil
+}
+
+// Wait until the node on mach is within one block of the highest of its peers.
+// If none of the peers can be reached, wait until the node makes progress on its own.
+func waitForCatchUp(mach, app string, peers []string, timeout time.Duration) error {
+	peerRPCAddrs := []string{}
+	for _, peer := range peers {
+		_, rpcAddr, err := getCoreAddrs(peer, app)
+		if err != nil {
+			fmt.Println(Yellow(err.Error()))
+			continue
+		}
+		peerRPCAddrs = append(peerRPCAddrs, rpcAddr)
+	}
+
+	deadline := time.Now().Add(timeout)
+	rpcAddr := ""
+	startHeight := -1
+	for time.Now().Before(deadline) {
+		time.Sleep(time.Second * 2)
+		if rpcAddr == "" {
+			var err error
+			if _, rpcAddr, err = getCoreAddrs(mach, app); err != nil {
+				continue
+			}
+		}
+		status, err := getCoreStatus(rpcAddr)
+		if err != nil {
+			fmt.Println(Yellow(Fmt("tendermint not yet responding on %v. Waiting...", mach)))
+			continue
+		}
+		height := status.LatestBlockHeight
+		if startHeight == -1 {
+			startHeight = height
+		}
+
+		peerHeight := -1
+		for _, peerRPCAddr := range peerRPCAddrs {
+			peerStatus, err := getCoreStatus(peerRPCAddr)
+			if err != nil {
+				continue
+			}
+			if peerStatus.LatestBlockHeight > peerHeight {
+				peerHeight = peerStatus.LatestBlockHeight
+			}
+		}
+
+		if peerHeight == -1 {
+			if height > startHeight {
+				fmt.Println(Green(Fmt("%v is making progress at height %v", mach, height)))
+				return nil
+			}
+		} else if height+1 >= peerHeight {
+			fmt.Println(Green(Fmt("%v caught up at height %v (peers at %v)", mach, height, peerHeight)))
+			return nil
+		}
+		fmt.Println(Yellow(Fmt("%v at height %v, peers at %v. Waiting...", mach, height, peerHeight)))
+	}
+	return errors.New(Fmt("Timed out waiting for %v to catch up", mach))
+}
+
+// Whole seconds left until the deadline, at least one so a ReadyCheck doesn't fall back to its default
+func remainingSeconds(deadline time.Time) int {
+	if seconds := int(deadline.Sub(time.Now()) / time.Second); seconds > 0 {
+		return seconds
+	}
+	return 1
+}
@@ -1,10 +1,13 @@
 package main
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"os/exec"
 	"strings"
+	"syscall"
 
 	. "github.com/tendermint/go-common"
 	pcm "github.com/tendermint/go-process"
@@ -13,7 +16,7 @@ import (
 
 // Copy a file (or dir recursively) from srcPath (local machine) to
 // dstPath in the tmcore container.
-func copyToMachine(mach string, app string, srcPath string, dstPath string, copyContents bool) error {
+func copyToMachine(mach string, app string, proj *Project, srcPath string, dstPath string, copyContents bool) error {
 
 	// First, copy the file to a temporary location
 	// in the machine.
@@ -34,7 +37,8 @@ func copyToMachine(mach string, app string, srcPath string, dstPath string, copy
 
 	// Next, change the ownership of the file to tmuser
 	// TODO We don't really want to change all the permissions
-	args = []string{"ssh", mach, Fmt(`docker run --rm --volumes-from %v_tmcommon -u root tendermint/tmbase chown -R tmuser:tmuser %v`, app, dstPath)}
+	image := proj.Component(mach, "common").Image
+	args = []string{"ssh", mach, Fmt(`docker run --rm --volumes-from %v_tmcommon -u root --entrypoint chown %v -R tmuser:tmuser %v`, app, image, dstPath)}
 	if !runProcess("docker-chmod-file-"+mach, "docker-machine", args, true) {
 		return errors.New("Failed to docker-run(chmod) file in machine " + mach)
 	}
@@ -85,8 +89,46 @@ func runProcessGetResult(label string, command string, args []string, verbose bo
 	}
 }
 
+// Like runProcessGetResult, but keeps stdout and stderr apart and returns the exit code.
+// err is only set if the process could not be run at all.
+func runProcessGetOutput(label string, command string, args []string, verbose bool) (stdout, stderr string, exitCode int, err error) {
+	var outBuf, errBuf bytes.Buffer
+	cmd := exec.Command(command, args...)
+	cmd.Stdout = &outBuf
+	cmd.Stderr = &errBuf
+	if verbose {
+		fmt.Println(Green(command), Green(args))
+	}
+	err = cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+			return outBuf.String(), errBuf.String(), status.ExitStatus(), nil
+		}
+		return outBuf.String(), errBuf.String(), -1, nil
+	} else if err != nil {
+		if verbose {
+			fmt.Println(Red(label + ": " + err.Error()))
+		}
+		return "", "", -1, err
+	}
+	return outBuf.String(), errBuf.String(), 0, nil
+}
+
 //--------------------------------------------------------------------------------
 
+// Quotes s as a single word for a remote shell
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
 func eB(s string) string {
 	s = strings.Replace(s, `\`, `\\`, -1)
 	s = strings.Replace(s, `$`, `\$`, -1)
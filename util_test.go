@@ -0,0 +1,22 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestShellJoin(t *testing.T) {
+	cases := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"ls", "-la"}, `'ls' '-la'`},
+		{[]string{"echo", "hello world"}, `'echo' 'hello world'`},
+		{[]string{"echo", "it's"}, `'echo' 'it'\''s'`},
+		{[]string{"sh", "-c", "echo $HOME; ls"}, `'sh' '-c' 'echo $HOME; ls'`},
+	}
+	for _, c := range cases {
+		if got := shellJoin(c.args); got != c.expected {
+			t.Errorf("Expected %v but got %v", c.expected, got)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"text/tabwriter"
+
+	. "github.com/tendermint/go-common"
+
+	"github.com/codegangsta/cli"
+)
+
+// The blockchain rpc returns at most this many block metas per call
+const verifyBatchSize = 20
+
+// The hashes a node reports for a height
+type headerHashes struct {
+	BlockHash string
+	AppHash   string // app state after the previous block
+}
+
+// Where nodes first disagree, with the machines grouped by the hash they report
+type divergence struct {
+	Height int
+	Field  string // "block hash" or "app hash"
+	Groups map[string][]string
+}
+
+//--------------------------------------------------------------------------------
+
+// Compare the block and app hashes of every node height by height, and report the first disagreement.
+// Exits non-zero if the nodes diverge.
+func cmdVerify(c *cli.Context) {
+	args := c.Args()
+	machines, state := parseNetworkMachines(c)
+	app, ok := parseNetworkApp(args, state)
+	if !ok {
+		cli.ShowAppHelp(c)
+		return
+	}
+
+	// Only heights every node has can be compared
+	rpcAddrs := make(map[string]string)
+	latest := 0
+	for _, mach := range machines {
+		rpcAddr, err := getRPCAddr(state, mach, app)
+		if err != nil {
+			fmt.Println(Yellow(Fmt("Skipping %v: %v", mach, err)))
+			continue
+		}
+		status, err := getCoreStatus(rpcAddr)
+		if err != nil {
+			fmt.Println(Yellow(Fmt("Skipping %v: %v", mach, err)))
+			continue
+		}
+		if len(rpcAddrs) == 0 || status.LatestBlockHeight < latest {
+			latest = status.LatestBlockHeight
+		}
+		rpcAddrs[mach] = rpcAddr
+	}
+	if len(rpcAddrs) < 2 {
+		Exit("Need at least two nodes to compare")
+	}
+	reachable := []string{}
+	for _, mach := range machines {
+		if _, ok := rpcAddrs[mach]; ok {
+			reachable = append(reachable, mach)
+		}
+	}
+
+	from, to := c.Int("from-height"), c.Int("to-height")
+	if to == 0 || to > latest {
+		to = latest
+	}
+	if from < 1 || from > to {
+		Exit(Fmt("Invalid height range %v to %v, the lowest latest height is %v", from, to, latest))
+	}
+
+	for minHeight := from; minHeight <= to; minHeight += verifyBatchSize {
+		maxHeight := minHeight + verifyBatchSize - 1
+		if maxHeight > to {
+			maxHeight = to
+		}
+		headers, err := fetchHeaderHashes(reachable, rpcAddrs, minHeight, maxHeight)
+		if err != nil {
+			Exit(err.Error())
+		}
+		if div := firstDivergence(reachable, minHeight, maxHeight, headers); div != nil {
+			printDivergence(div)
+			Exit(Fmt("Nodes diverge at height %v", div.Height))
+		}
+	}
+	fmt.Println(Green(Fmt("All %v nodes agree on heights %v to %v", len(reachable), from, to)))
+}
+
+// Returns the hashes of each machine's blocks from minHeight to maxHeight
+func fetchHeaderHashes(machines []string, rpcAddrs map[string]string, minHeight, maxHeight int) (map[string]map[int]*headerHashes, error) {
+	headers := make(map[string]map[int]*headerHashes)
+	var mtx sync.Mutex
+	results := fanOut(machines, 0, func(res *machResult) {
+		info, err := getBlockchainInfo(rpcAddrs[res.Mach], minHeight, maxHeight)
+		if res.Step("blockchain", err) != nil {
+			return
+		}
+		hashes := make(map[int]*headerHashes)
+		for _, meta := range info.BlockMetas {
+			hashes[meta.Header.Height] = &headerHashes{
+				BlockHash: Fmt("%X", meta.Hash),
+				AppHash:   Fmt("%X", meta.Header.AppHash),
+			}
+		}
+		mtx.Lock()
+		headers[res.Mach] = hashes
+		mtx.Unlock()
+	})
+	for _, res := range results {
+		if res.Failed() {
+			return nil, fmt.Errorf("Failed to get blocks %v to %v from %v: %v", minHeight, maxHeight, res.Mach, res.Steps[0].Err)
+		}
+	}
+	return headers, nil
+}
+
+// Returns the first height where the machines report different hashes, or nil.
+// A missing header counts as its own hash.
+func firstDivergence(machines []string, minHeight, maxHeight int, headers map[string]map[int]*headerHashes) *divergence {
+	for height := minHeight; height <= maxHeight; height++ {
+		blockHashes := make(map[string][]string)
+		appHashes := make(map[string][]string)
+		for _, mach := range machines {
+			hashes, ok := headers[mach][height]
+			if !ok {
+				hashes = &headerHashes{BlockHash: "missing", AppHash: "missing"}
+			}
+			blockHashes[hashes.BlockHash] = append(blockHashes[hashes.BlockHash], mach)
+			appHashes[hashes.AppHash] = append(appHashes[hashes.AppHash], mach)
+		}
+		// A different app hash at height is the earlier cause, as it comes from the previous block
+		if len(appHashes) > 1 {
+			return &divergence{height, "app hash", appHashes}
+		}
+		if len(blockHashes) > 1 {
+			return &divergence{height, "block hash", blockHashes}
+		}
+	}
+	return nil
+}
+
+func printDivergence(div *divergence) {
+	fmt.Println(Red(Fmt("The %v differs at height %v", div.Field, div.Height)))
+	if div.Field == "app hash" {
+		fmt.Println(Red(Fmt("The apps computed different states for block %v", div.Height-1)))
+	}
+	hashes := []string{}
+	for hash := range div.Groups {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "HASH\tMACHINES")
+	for _, hash := range hashes {
+		fmt.Fprintln(w, Fmt("%v\t%v", hash, div.Groups[hash]))
+	}
+	w.Flush()
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFirstDivergence(t *testing.T) {
+	machines := []string{"mach1", "mach2", "mach3"}
+	headers := map[string]map[int]*headerHashes{
+		"mach1": {1: {"B1", "A0"}, 2: {"B2", "A1"}, 3: {"B3", "A2"}},
+		"mach2": {1: {"B1", "A0"}, 2: {"B2", "A1"}, 3: {"B3x", "A2x"}},
+		"mach3": {1: {"B1", "A0"}, 2: {"B2", "A1"}, 3: {"B3", "A2"}},
+	}
+	if div := firstDivergence(machines, 1, 2, headers); div != nil {
+		t.Errorf("Unexpected divergence at %v", div.Height)
+	}
+	div := firstDivergence(machines, 1, 3, headers)
+	if div == nil || div.Height != 3 || div.Field != "app hash" {
+		t.Fatalf("Expected the app hash to diverge at 3, got %v", div)
+	}
+	if strings.Join(div.Groups["A2x"], ",") != "mach2" || strings.Join(div.Groups["A2"], ",") != "mach1,mach3" {
+		t.Errorf("Unexpected groups %v", div.Groups)
+	}
+
+	headers["mach3"][3] = &headerHashes{"B3y", "A2"}
+	headers["mach2"][3] = &headerHashes{"B3", "A2"}
+	div = firstDivergence(machines, 1, 3, headers)
+	if div == nil || div.Field != "block hash" || strings.Join(div.Groups["B3y"], ",") != "mach3" {
+		t.Errorf("Expected the block hash of mach3 to diverge, got %v", div)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/tendermint/go-common"
+)
+
+// A container's state as docker reports it
+type containerState struct {
+	Status   string // running, exited, paused, ...
+	Restarts int
+}
+
+// What a poll saw of one node
+type nodeSnapshot struct {
+	Mach        string
+	RPCUp       bool
+	RPCErr      error
+	Height      int
+	BlockTime   time.Time
+	Peers       int
+	MempoolSize int
+	RPCLatency  time.Duration
+	Containers  map[string]containerState // by component
+}
+
+// Polls the nodes of a network, remembering their rpc addresses once found
+type nodeWatcher struct {
+	app      string
+	proj     *Project
+	state    *NetworkState
+	machines []string
+
+	mtx      sync.Mutex
+	rpcAddrs map[string]string
+}
+
+func newNodeWatcher(app string, proj *Project, state *NetworkState, machines []string) *nodeWatcher {
+	return &nodeWatcher{
+		app:      app,
+		proj:     proj,
+		state:    state,
+		machines: machines,
+		rpcAddrs: make(map[string]string),
+	}
+}
+
+// Polls every node at once. Snapshots are in the order of machines
+func (w *nodeWatcher) poll(withContainers bool) []*nodeSnapshot {
+	snapshots := make([]*nodeSnapshot, len(w.machines))
+	fanOut(w.machines, 0, func(res *machResult) {
+		snapshots[res.Index] = w.pollNode(res.Mach, withContainers)
+	})
+	return snapshots
+}
+
+func (w *nodeWatcher) rpcAddr(mach string) (string, error) {
+	w.mtx.Lock()
+	rpcAddr, ok := w.rpcAddrs[mach]
+	w.mtx.Unlock()
+	if ok {
+		return rpcAddr, nil
+	}
+	rpcAddr, err := getRPCAddr(w.state, mach, w.app)
+	if err != nil {
+		return "", err
+	}
+	w.mtx.Lock()
+	w.rpcAddrs[mach] = rpcAddr
+	w.mtx.Unlock()
+	return rpcAddr, nil
+}
+
+func (w *nodeWatcher) pollNode(mach string, withContainers bool) *nodeSnapshot {
+	snap := &nodeSnapshot{Mach: mach}
+	if withContainers {
+		snap.Containers = make(map[string]containerState)
+		machs := []string{mach}
+		if appMach := w.proj.AppMachine(mach); appMach != mach {
+			machs = append(machs, appMach)
+		}
+		for _, m := range machs {
+			states, err := getContainerStates(m, w.app)
+			if err != nil {
+				continue
+			}
+			for component, state := range states {
+				snap.Containers[component] = state
+			}
+		}
+	}
+
+	rpcAddr, err := w.rpcAddr(mach)
+	if err != nil {
+		snap.RPCErr = err
+		return snap
+	}
+	start := time.Now()
+	status, err := getCoreStatus(rpcAddr)
+	snap.RPCLatency = time.Since(start)
+	if err != nil {
+		snap.RPCErr = err
+		return snap
+	}
+	snap.RPCUp = true
+	snap.Height = status.LatestBlockHeight
+	snap.BlockTime = time.Unix(0, status.LatestBlockTime)
+	if netInfo, err := getNetInfo(rpcAddr); err == nil {
+		snap.Peers = len(netInfo.Peers)
+	}
+	if size, err := getMempoolSize(rpcAddr); err == nil {
+		snap.MempoolSize = size
+	}
+	return snap
+}
+
+// Returns the state of the app's containers on mach by component
+func getContainerStates(mach, app string) (map[string]containerState, error) {
+	cmd := Fmt(`docker inspect --format "{{.Name}} {{.State.Status}} {{.RestartCount}}" $(docker ps -aq --filter name=%v_tm)`, app)
+	output, ok := runProcessGetResult("container-states-"+mach, "docker-machine", []string{"ssh", mach, cmd}, false)
+	if !ok {
+		return nil, errors.New("Failed to inspect containers on machine " + mach)
+	}
+	states := make(map[string]containerState)
+	prefix := "/" + app + "_tm"
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 || !strings.HasPrefix(fields[0], prefix) {
+			continue
+		}
+		restarts, _ := strconv.Atoi(fields[2])
+		states[strings.TrimPrefix(fields[0], prefix)] = containerState{Status: fields[1], Restarts: restarts}
+	}
+	return states, nil
+}
+
+// The highest height among the snapshots
+func maxSnapshotHeight(snapshots []*nodeSnapshot) int {
+	max := 0
+	for _, snap := range snapshots {
+		if snap.RPCUp && snap.Height > max {
+			max = snap.Height
+		}
+	}
+	return max
+}